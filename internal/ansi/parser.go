@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Style represents the current text styling
@@ -216,6 +217,12 @@ func HighlightText(segments []Segment, searchTerm string, targetMatchIndex int)
 		return segments
 	}
 
+	return HighlightRange(segments, targetMatchIndex, len(searchTerm))
+}
+
+// HighlightRange highlights length bytes of the stripped text starting at
+// byte index targetMatchIndex
+func HighlightRange(segments []Segment, targetMatchIndex, length int) []Segment {
 	var result []Segment
 
 	// Track current position in the stripped text
@@ -235,11 +242,11 @@ func HighlightText(segments []Segment, searchTerm string, targetMatchIndex int)
 		// Case 2: Match starts in this segment
 
 		// Calculate overlap with the target match
-		// The target match spans from targetMatchIndex to targetMatchIndex + len(searchTerm)
+		// The target match spans from targetMatchIndex to targetMatchIndex + length
 		// This segment spans from currentPos to currentPos + segLen
 
 		matchStart := targetMatchIndex
-		matchEnd := targetMatchIndex + len(searchTerm)
+		matchEnd := targetMatchIndex + length
 		segStart := currentPos
 		segEnd := currentPos + segLen
 
@@ -292,3 +299,98 @@ func HighlightText(segments []Segment, searchTerm string, targetMatchIndex int)
 
 	return result
 }
+
+// Truncate cuts segments so that at most maxLen characters of text remain
+func Truncate(segments []Segment, maxLen int) []Segment {
+	var result []Segment
+	remaining := maxLen
+
+	for _, seg := range segments {
+		if remaining <= 0 {
+			break
+		}
+
+		n := utf8.RuneCountInString(seg.Text)
+		if n <= remaining {
+			result = append(result, seg)
+			remaining -= n
+			continue
+		}
+
+		// Cut at a rune boundary
+		cut := 0
+		for i := 0; i < remaining; i++ {
+			_, size := utf8.DecodeRuneInString(seg.Text[cut:])
+			cut += size
+		}
+		result = append(result, Segment{
+			Text:  seg.Text[:cut],
+			Style: seg.Style,
+		})
+		remaining = 0
+	}
+
+	return result
+}
+
+// Sanitize makes segments safe to print to the terminal. Control
+// characters are shown in caret notation (^M, ^G, ^[ for stray escapes)
+// and bytes that are not valid UTF-8 as highlighted <XX> markers.
+func Sanitize(segments []Segment) []Segment {
+	var result []Segment
+
+	for _, seg := range segments {
+		if isPrintable(seg.Text) {
+			result = append(result, seg)
+			continue
+		}
+
+		var sb strings.Builder
+		flush := func() {
+			if sb.Len() > 0 {
+				result = append(result, Segment{Text: sb.String(), Style: seg.Style})
+				sb.Reset()
+			}
+		}
+		marker := func(text string) {
+			flush()
+			style := seg.Style
+			style.Reverse = true
+			result = append(result, Segment{Text: text, Style: style})
+		}
+
+		for i := 0; i < len(seg.Text); {
+			r, size := utf8.DecodeRuneInString(seg.Text[i:])
+			switch {
+			case r == utf8.RuneError && size == 1:
+				marker(fmt.Sprintf("<%02X>", seg.Text[i]))
+			case r == '\t':
+				sb.WriteRune(r)
+			case r < 0x20:
+				sb.WriteByte('^')
+				sb.WriteByte(byte(r) + '@')
+			case r == 0x7f:
+				sb.WriteString("^?")
+			case r >= 0x80 && r < 0xa0: // C1 control characters
+				marker(fmt.Sprintf("<U+%04X>", r))
+			default:
+				sb.WriteString(seg.Text[i : i+size])
+			}
+			i += size
+		}
+		flush()
+	}
+
+	return result
+}
+
+// isPrintable reports whether text is plain printable ASCII or tabs
+func isPrintable(text string) bool {
+	for i := 0; i < len(text); i++ {
+		b := text[i]
+		if (b < 0x20 && b != '\t') || b >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,333 @@
+package reader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// tarMagicOffset is where the "ustar" magic is found in a tar header
+const tarMagicOffset = 257
+
+// archiveMember is a regular file in an archive
+type archiveMember struct {
+	name    string
+	size    int64
+	modTime time.Time
+	entry   int // Position of the member among all archive entries
+}
+
+// ArchiveReader lists the members of a tar or zip archive, one per line.
+// Members are opened for viewing without extracting anything to disk.
+type ArchiveReader struct {
+	mu       sync.Mutex
+	path     string
+	opts     Options
+	zip      *zip.ReadCloser // Open zip archive, nil for tar
+	members  []archiveMember
+	loaded   bool
+	listed   bool
+	err      error
+	updates  chan struct{}
+	filename string
+}
+
+// IsArchive reports whether a file is a zip archive or a tar archive,
+// possibly compressed
+func IsArchive(filename string) bool {
+	kind, _ := archiveKind(filename)
+	return kind != ""
+}
+
+// archiveKind returns "zip" or "tar" for archives, or "" for other files
+func archiveKind(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", err
+	}
+
+	header := make([]byte, 4)
+	if n, _ := f.ReadAt(header, 0); n == 4 &&
+		(bytes.Equal(header, []byte("PK\x03\x04")) || bytes.Equal(header, []byte("PK\x05\x06"))) {
+		return "zip", nil
+	}
+
+	r, err := decompress(f)
+	if err != nil {
+		return "", nil
+	}
+	block := make([]byte, tarMagicOffset+5)
+	if _, err := io.ReadFull(r, block); err != nil {
+		return "", nil
+	}
+	if string(block[tarMagicOffset:]) == "ustar" {
+		return "tar", nil
+	}
+	return "", nil
+}
+
+// NewArchiveReader opens a tar or zip archive for browsing. Options apply
+// to the members opened from it.
+func NewArchiveReader(filename string, opts Options) (*ArchiveReader, error) {
+	kind, err := archiveKind(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	ar := &ArchiveReader{
+		path:     filename,
+		opts:     opts,
+		updates:  make(chan struct{}, 1),
+		filename: filename,
+	}
+
+	switch kind {
+	case "":
+		return nil, fmt.Errorf("%s is not a tar or zip archive", filename)
+	case "zip":
+		zr, err := zip.OpenReader(filename)
+		if err != nil {
+			return nil, err
+		}
+		ar.zip = zr
+	}
+
+	return ar, nil
+}
+
+// Load lists the archive members in the background
+func (ar *ArchiveReader) Load() error {
+	ar.mu.Lock()
+	if ar.loaded {
+		ar.mu.Unlock()
+		return nil
+	}
+	ar.loaded = true
+	ar.mu.Unlock()
+
+	go ar.list()
+	return nil
+}
+
+// list reads the member list, which for tar means reading the whole archive
+func (ar *ArchiveReader) list() {
+	var err error
+	if ar.zip != nil {
+		for i, f := range ar.zip.File {
+			if f.Mode().IsRegular() {
+				ar.addMember(archiveMember{
+					name:    f.Name,
+					size:    int64(f.UncompressedSize64),
+					modTime: f.Modified,
+					entry:   i,
+				})
+			}
+		}
+	} else {
+		err = ar.walkTar(func(hdr *tar.Header, entry int) {
+			if hdr.Typeflag == tar.TypeReg {
+				ar.addMember(archiveMember{
+					name:    hdr.Name,
+					size:    hdr.Size,
+					modTime: hdr.ModTime,
+					entry:   entry,
+				})
+			}
+		})
+	}
+
+	ar.mu.Lock()
+	ar.listed = true
+	ar.err = err
+	ar.mu.Unlock()
+
+	ar.notify()
+}
+
+// addMember adds a member to the list
+func (ar *ArchiveReader) addMember(m archiveMember) {
+	ar.mu.Lock()
+	ar.members = append(ar.members, m)
+	ar.mu.Unlock()
+
+	ar.notify()
+}
+
+// walkTar calls fn with the header of each tar entry and its position
+func (ar *ArchiveReader) walkTar(fn func(hdr *tar.Header, entry int)) error {
+	f, err := os.Open(ar.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompress(f)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for entry := 0; ; entry++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(hdr, entry)
+	}
+}
+
+// Choose opens the member shown on the given line
+func (ar *ArchiveReader) Choose(line int) (LineSource, error) {
+	ar.mu.Lock()
+	if line < 0 || line >= len(ar.members) {
+		ar.mu.Unlock()
+		return nil, errors.New("line index out of bounds")
+	}
+	m := ar.members[line]
+	ar.mu.Unlock()
+
+	name := ar.filename + ":" + m.name
+
+	if ar.zip != nil {
+		rc, err := ar.zip.File[m.entry].Open()
+		if err != nil {
+			return nil, err
+		}
+		return NewStreamReader(name, rc, ar.opts)
+	}
+
+	// Tar members can only be reached by reading up to them
+	f, err := os.Open(ar.path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	tr := tar.NewReader(r)
+	for entry := 0; entry <= m.entry; entry++ {
+		if _, err := tr.Next(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return NewStreamReader(name, tarMember{Reader: tr, file: f}, ar.opts)
+}
+
+// tarMember reads a tar member and closes the archive when done
+type tarMember struct {
+	*tar.Reader
+	file *os.File
+}
+
+// Close closes the archive file
+func (tm tarMember) Close() error {
+	return tm.file.Close()
+}
+
+// notify signals that the member list changed without blocking
+func (ar *ArchiveReader) notify() {
+	select {
+	case ar.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Updates returns a channel that receives a value whenever members are listed
+func (ar *ArchiveReader) Updates() <-chan struct{} {
+	return ar.updates
+}
+
+// Loading reports whether the archive is still being listed
+func (ar *ArchiveReader) Loading() bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return !ar.listed
+}
+
+// Err returns the error that stopped listing, if any
+func (ar *ArchiveReader) Err() error {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return ar.err
+}
+
+// LineCount returns the number of members listed so far
+func (ar *ArchiveReader) LineCount() int {
+	ar.Load()
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return len(ar.members)
+}
+
+// GetLine returns the member shown on the specified line (0-based)
+func (ar *ArchiveReader) GetLine(index int) (string, error) {
+	lines, err := ar.GetLines(index, index+1)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", errors.New("line index out of bounds")
+	}
+
+	return lines[0], nil
+}
+
+// GetLines returns the members shown on lines [start, end), with their
+// size and modification time
+func (ar *ArchiveReader) GetLines(start, end int) ([]string, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if start < 0 {
+		start = 0
+	}
+	if end > len(ar.members) {
+		end = len(ar.members)
+	}
+	if start >= end {
+		return []string{}, nil
+	}
+
+	lines := make([]string, 0, end-start)
+	for _, m := range ar.members[start:end] {
+		lines = append(lines, fmt.Sprintf("%12d  %s  %s",
+			m.size, m.modTime.Format("2006-01-02 15:04"), m.name))
+	}
+	return lines, nil
+}
+
+// Filename returns the name of the archive
+func (ar *ArchiveReader) Filename() string {
+	return ar.filename
+}
+
+// Close closes the archive
+func (ar *ArchiveReader) Close() error {
+	if ar.zip != nil {
+		return ar.zip.Close()
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// changedMarker is the gutter shown next to lines that were not in the
+// output of the previous run
+const changedMarker = "\x1b[1;33m▌\x1b[0m"
+
+// CommandReader shows the combined stdout and stderr of a shell command.
+// The command can be re-run, by hand or on a timer, and lines that were
+// not in the output of the previous run are marked.
+type CommandReader struct {
+	mu       sync.Mutex
+	reloadMu sync.Mutex // Serializes starting and switching runs
+	command  string
+	opts     Options
+	interval time.Duration   // Re-run period, 0 to only re-run by hand
+	output   *FileReader     // Output of the run shown
+	next     *FileReader     // Output of a new run, until it replaces output
+	previous map[string]bool // Lines of the previous run
+	runs     int
+	started  time.Time
+	nextRun  time.Time // When the new run started
+	loaded   bool
+	err      error // Error re-running the command
+	updates  chan struct{}
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+// commandOutput is the output of a running command. Closing it stops
+// the command.
+type commandOutput struct {
+	*io.PipeReader
+	cmd *exec.Cmd
+}
+
+// Close stops the command and closes its output
+func (co commandOutput) Close() error {
+	co.cmd.Process.Kill()
+	return co.PipeReader.Close()
+}
+
+// NewCommandReader creates a reader for the output of a shell command,
+// re-run every interval if it is not zero
+func NewCommandReader(command string, interval time.Duration, opts Options) (*CommandReader, error) {
+	if _, err := normalizeEncoding(opts.Encoding); err != nil {
+		return nil, err
+	}
+
+	return &CommandReader{
+		command:  command,
+		opts:     opts,
+		interval: interval,
+		updates:  make(chan struct{}, 1),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Load runs the command and starts watching its output
+func (cr *CommandReader) Load() error {
+	cr.mu.Lock()
+	if cr.loaded {
+		cr.mu.Unlock()
+		return nil
+	}
+	cr.loaded = true
+	cr.mu.Unlock()
+
+	if err := cr.Reload(); err != nil {
+		return err
+	}
+
+	go cr.watch()
+	return nil
+}
+
+// Reload runs the command again. The output of the previous run is
+// shown until the new run completes or has output as many lines.
+func (cr *CommandReader) Reload() error {
+	cr.reloadMu.Lock()
+	output, err := cr.run()
+	if err != nil {
+		cr.reloadMu.Unlock()
+		return err
+	}
+
+	// A run not shown yet is replaced by this one
+	cr.mu.Lock()
+	pending := cr.next
+	cr.next = output
+	cr.nextRun = time.Now()
+	cr.mu.Unlock()
+	cr.reloadMu.Unlock()
+
+	if pending != nil {
+		pending.Close()
+	}
+
+	cr.promote()
+
+	select {
+	case cr.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// promote shows the output of the new run once it is complete or has
+// caught up with the output shown, so re-running keeps the screen filled
+func (cr *CommandReader) promote() {
+	cr.reloadMu.Lock()
+	defer cr.reloadMu.Unlock()
+
+	cr.mu.Lock()
+	next, old := cr.next, cr.output
+	cr.mu.Unlock()
+
+	if next == nil || (old != nil && next.Loading() && next.LineCount() < old.LineCount()) {
+		return
+	}
+
+	// Remember the lines of the run being replaced to mark changes
+	var previous map[string]bool
+	if old != nil {
+		lines, _ := old.GetLines(0, old.LineCount())
+		previous = make(map[string]bool, len(lines))
+		for _, line := range lines {
+			previous[line] = true
+		}
+	}
+
+	cr.mu.Lock()
+	cr.output = next
+	cr.next = nil
+	cr.previous = previous
+	cr.runs++
+	cr.started = cr.nextRun
+	cr.err = nil
+	cr.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	cr.notify()
+}
+
+// run starts the command and returns a reader for its output
+func (cr *CommandReader) run() (*FileReader, error) {
+	cmd := exec.Command(shell, shellFlag, cr.command)
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// A failing command is reported once all of its output is read
+	go func() {
+		pw.CloseWithError(cmd.Wait())
+	}()
+
+	output, err := NewStreamReader(cr.command, commandOutput{PipeReader: pr, cmd: cmd}, cr.opts)
+	if err != nil {
+		cmd.Process.Kill()
+		pr.Close()
+		return nil, err
+	}
+	output.Load()
+	return output, nil
+}
+
+// watch passes on updates of the current run and re-runs the command
+// when its interval has passed
+func (cr *CommandReader) watch() {
+	var tick <-chan time.Time
+	if cr.interval > 0 {
+		ticker := time.NewTicker(cr.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		cr.mu.Lock()
+		output, next := cr.output, cr.next
+		cr.mu.Unlock()
+
+		var outputUpdates, nextUpdates <-chan struct{}
+		if output != nil {
+			outputUpdates = output.Updates()
+		}
+		if next != nil {
+			nextUpdates = next.Updates()
+		}
+
+		select {
+		case <-cr.done:
+			return
+		case <-cr.wake:
+		case <-outputUpdates:
+			cr.notify()
+		case <-nextUpdates:
+			cr.promote()
+		case <-tick:
+			// A run slower than the interval is left to finish
+			if next != nil {
+				continue
+			}
+			if err := cr.Reload(); err != nil {
+				cr.mu.Lock()
+				cr.err = err
+				cr.mu.Unlock()
+				cr.notify()
+			}
+		}
+	}
+}
+
+// current returns the output of the current run
+func (cr *CommandReader) current() *FileReader {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	return cr.output
+}
+
+// notify signals that the output changed without blocking
+func (cr *CommandReader) notify() {
+	select {
+	case cr.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Updates returns a channel that receives a value whenever the output
+// grows or the command is re-run
+func (cr *CommandReader) Updates() <-chan struct{} {
+	return cr.updates
+}
+
+// Loading reports whether the command is still producing output
+func (cr *CommandReader) Loading() bool {
+	cr.mu.Lock()
+	output, next := cr.output, cr.next
+	cr.mu.Unlock()
+
+	return output == nil || next != nil || output.Loading()
+}
+
+// Err returns the error that ended the current run or kept the command
+// from running again, if any
+func (cr *CommandReader) Err() error {
+	cr.mu.Lock()
+	output, err := cr.output, cr.err
+	cr.mu.Unlock()
+
+	if err == nil && output != nil {
+		err = output.Err()
+	}
+	return err
+}
+
+// LineCount returns the number of lines output so far by the current run
+func (cr *CommandReader) LineCount() int {
+	cr.Load()
+
+	output := cr.current()
+	if output == nil {
+		return 0
+	}
+	return output.LineCount()
+}
+
+// GetLine returns the output line at the specified index (0-based)
+func (cr *CommandReader) GetLine(index int) (string, error) {
+	output := cr.current()
+	if output == nil {
+		return "", errors.New("line index out of bounds")
+	}
+	return output.GetLine(index)
+}
+
+// GetLines returns a range of output lines [start, end)
+func (cr *CommandReader) GetLines(start, end int) ([]string, error) {
+	output := cr.current()
+	if output == nil {
+		return []string{}, nil
+	}
+	return output.GetLines(start, end)
+}
+
+// Gutter marks lines that were not in the output of the previous run
+func (cr *CommandReader) Gutter(index int) string {
+	cr.mu.Lock()
+	output, previous := cr.output, cr.previous
+	cr.mu.Unlock()
+
+	if output == nil || previous == nil {
+		return ""
+	}
+
+	line, err := output.GetLine(index)
+	if err != nil || previous[line] {
+		return ""
+	}
+	return changedMarker
+}
+
+// HasGutter returns true, any line can differ from the previous run
+func (cr *CommandReader) HasGutter() bool {
+	return true
+}
+
+// Filename returns the command and when it last ran
+func (cr *CommandReader) Filename() string {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	name := "$ " + cr.command
+	if cr.runs > 0 {
+		name += fmt.Sprintf(" (run %d at %s)", cr.runs, cr.started.Format("15:04:05"))
+	}
+	return name
+}
+
+// Close stops watching and the running command
+func (cr *CommandReader) Close() error {
+	select {
+	case <-cr.done:
+		return nil
+	default:
+		close(cr.done)
+	}
+
+	cr.mu.Lock()
+	output, next := cr.output, cr.next
+	cr.mu.Unlock()
+
+	if next != nil {
+		next.Close()
+	}
+	if output != nil {
+		return output.Close()
+	}
+	return nil
+}
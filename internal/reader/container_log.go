@@ -0,0 +1,454 @@
+package reader
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Container log formats
+const (
+	formatPlain  = "plain"
+	formatDocker = "docker" // {"log":"msg\n","stream":"stdout","time":"..."}
+	formatCRI    = "cri"    // 2024-01-01T00:00:00Z stdout F msg
+)
+
+// containerRecord is a log message with its stream and time
+type containerRecord struct {
+	prefix string // Time and stream, shown dimmed before the message
+	text   string
+	stderr bool
+}
+
+// ContainerLogReader shows Docker json-file and Kubernetes CRI logs as
+// their messages, joining messages split into partial lines. The time
+// and stream of each message are shown as a dimmed prefix that can be
+// turned off. Files in other formats are shown unchanged.
+type ContainerLogReader struct {
+	*FileReader
+
+	mu         sync.Mutex
+	format     string // Detected from the first line, "" until it is read
+	hidePrefix bool
+	index      *recordIndex[[]containerRecord]
+}
+
+// NewContainerLogReader creates a reader decoding container logs read by fr
+func NewContainerLogReader(fr *FileReader) *ContainerLogReader {
+	return &ContainerLogReader{
+		FileReader: fr,
+		index:      newRecordIndex[[]containerRecord](0),
+	}
+}
+
+// Load starts reading the file and finding its records in the background
+func (cr *ContainerLogReader) Load() error {
+	if !cr.index.begin() {
+		return nil
+	}
+
+	if err := cr.FileReader.Load(); err != nil {
+		return err
+	}
+
+	go cr.scan()
+	return nil
+}
+
+// scan detects the format, then finds the messages of container logs
+func (cr *ContainerLogReader) scan() {
+	switch format := cr.detect(); format {
+	case formatDocker, formatCRI:
+		cr.index.scan(cr.FileReader, longLineSize, func(line string) lineKind {
+			_, partial := parseContainerLine(format, line)
+			return lineKind{start: true, cont: partial}
+		})
+	case formatPlain:
+		cr.index.forward(cr.FileReader)
+	}
+}
+
+// detect waits for the first line and detects the format from it. A
+// followed file may still be writing it, so it waits for its newline. It
+// returns "" if the reader is closed first.
+func (cr *ContainerLogReader) detect() string {
+	for {
+		fr := cr.FileReader
+		if fr.settledLines() > 0 || fr.LineCount() > 0 && !fr.Loading() && !fr.Following() {
+			// The first line may be huge, only its start is needed
+			first, err := cr.FileReader.GetLinePrefixes(0, 1, longLineSize)
+			if err == nil && len(first) > 0 {
+				format := detectContainerLog(first[0])
+				cr.mu.Lock()
+				cr.format = format
+				cr.mu.Unlock()
+				return format
+			}
+		}
+
+		cr.index.notify()
+		select {
+		case <-cr.index.done:
+			return ""
+		case <-cr.FileReader.Updates():
+		}
+	}
+}
+
+// detectContainerLog returns the format of a log starting with line.
+// Container runtimes split long messages, so a first line as long as
+// longLineSize is not a container log.
+func detectContainerLog(line string) string {
+	if len(line) >= longLineSize {
+		return formatPlain
+	}
+	if _, ok := parseDockerLine(line); ok {
+		return formatDocker
+	}
+	if _, _, ok := parseCRILine(line); ok {
+		return formatCRI
+	}
+	return formatPlain
+}
+
+// parseContainerLine decodes a line of a container log, reporting whether
+// the message continues on the next line. Lines not in the format, such
+// as rotation markers, are kept as they are, and so are lines as long as
+// longLineSize, of which scanning only reads the start.
+func parseContainerLine(format string, line string) (containerRecord, bool) {
+	if len(line) >= longLineSize {
+		return containerRecord{text: line}, false
+	}
+
+	switch format {
+	case formatDocker:
+		entry, ok := parseDockerLine(line)
+		if !ok {
+			break
+		}
+		text, complete := strings.CutSuffix(entry.Log, "\n")
+		return containerRecord{
+			prefix: entry.Time + " " + entry.Stream,
+			text:   strings.TrimSuffix(text, "\r"),
+			stderr: entry.Stream == "stderr",
+		}, !complete
+	case formatCRI:
+		record, partial, ok := parseCRILine(line)
+		if !ok {
+			break
+		}
+		return record, partial
+	}
+	return containerRecord{text: line}, false
+}
+
+// dockerEntry is a line of a Docker json-file log
+type dockerEntry struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+// parseDockerLine decodes a line of a Docker json-file log
+func parseDockerLine(line string) (dockerEntry, bool) {
+	var entry dockerEntry
+	if !strings.HasPrefix(line, `{"log":`) || json.Unmarshal([]byte(line), &entry) != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// parseCRILine decodes a line of a CRI log: time, stream, a P or F tag
+// for partial or full lines, then the message
+func parseCRILine(line string) (containerRecord, bool, bool) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 3 {
+		return containerRecord{}, false, false
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fields[0]); err != nil {
+		return containerRecord{}, false, false
+	}
+	if fields[1] != "stdout" && fields[1] != "stderr" {
+		return containerRecord{}, false, false
+	}
+
+	// The tag may carry more flags after a colon
+	tag, _, _ := strings.Cut(fields[2], ":")
+	if tag != "P" && tag != "F" {
+		return containerRecord{}, false, false
+	}
+
+	record := containerRecord{
+		prefix: fields[0] + " " + fields[1],
+		stderr: fields[1] == "stderr",
+	}
+	if len(fields) == 4 {
+		record.text = fields[3]
+	}
+	return record, tag == "P", true
+}
+
+// decoding reports whether the file was detected as a container log
+func (cr *ContainerLogReader) decoding() bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	return cr.format == formatDocker || cr.format == formatCRI
+}
+
+// Updates returns a channel that receives a value whenever new records
+// become available
+func (cr *ContainerLogReader) Updates() <-chan struct{} {
+	return cr.index.updates
+}
+
+// Loading reports whether more records are expected
+func (cr *ContainerLogReader) Loading() bool {
+	if !cr.decoding() {
+		return cr.FileReader.Loading()
+	}
+	return cr.index.loading(cr.FileReader)
+}
+
+// LineCount returns the number of records found so far. A partial record
+// at the end counts once the file is completely read.
+func (cr *ContainerLogReader) LineCount() int {
+	cr.Load()
+
+	cr.mu.Lock()
+	format := cr.format
+	cr.mu.Unlock()
+
+	switch format {
+	case "":
+		return 0
+	case formatPlain:
+		return cr.FileReader.LineCount()
+	}
+
+	state := cr.index.state()
+	if state.cont && cr.FileReader.Loading() {
+		return state.records - 1
+	}
+	return state.records
+}
+
+// settledLines returns the number of records that can no longer change:
+// those before the last one, if it continues in lines yet to come or
+// that may still change
+func (cr *ContainerLogReader) settledLines() int {
+	cr.mu.Lock()
+	format := cr.format
+	cr.mu.Unlock()
+
+	switch format {
+	case "":
+		return 0
+	case formatPlain:
+		return cr.FileReader.settledLines()
+	}
+
+	state := cr.index.settledState()
+	if state.cont {
+		return state.records - 1
+	}
+	return state.records
+}
+
+// GetLine returns the record at the specified index (0-based)
+func (cr *ContainerLogReader) GetLine(index int) (string, error) {
+	lines, err := cr.GetLines(index, index+1)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", errors.New("line index out of bounds")
+	}
+
+	return lines[0], nil
+}
+
+// GetLines returns a range of records [start, end)
+func (cr *ContainerLogReader) GetLines(start, end int) ([]string, error) {
+	if !cr.decoding() {
+		return cr.FileReader.GetLines(start, end)
+	}
+	return cr.getLines(start, end, math.MaxInt)
+}
+
+// GetLinePrefixes returns a range of records [start, end), reading no
+// more than limit bytes of lines too long to be cached. Partial lines
+// stop being joined once a message reaches limit bytes.
+func (cr *ContainerLogReader) GetLinePrefixes(start, end, limit int) ([]string, error) {
+	if !cr.decoding() {
+		return cr.FileReader.GetLinePrefixes(start, end, limit)
+	}
+	return cr.getLines(start, end, limit)
+}
+
+// getLines returns a range of records, cut to limit bytes
+func (cr *ContainerLogReader) getLines(start, end, limit int) ([]string, error) {
+	if start < 0 {
+		start = 0
+	}
+	if end > cr.LineCount() {
+		end = cr.LineCount()
+	}
+	if start >= end {
+		return []string{}, nil
+	}
+
+	cr.mu.Lock()
+	hidePrefix := cr.hidePrefix
+	cr.mu.Unlock()
+
+	result := make([]string, 0, end-start)
+	for blockNum := start / indexStride; blockNum*indexStride < end; blockNum++ {
+		records, err := cr.getBlock(blockNum, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		first := blockNum * indexStride
+		from := min(max(start-first, 0), len(records))
+		to := min(end-first, len(records))
+		for _, record := range records[from:to] {
+			result = append(result, formatRecord(record, hidePrefix))
+		}
+		if to < end-first {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// formatRecord returns the line showing a record, with its prefix dimmed
+func formatRecord(record containerRecord, hidePrefix bool) string {
+	if record.prefix == "" || hidePrefix {
+		return record.text
+	}
+	return "\x1b[2m" + record.prefix + "\x1b[0m " + record.text
+}
+
+// getBlock returns the records of a block, joining partial lines up to
+// limit bytes
+func (cr *ContainerLogReader) getBlock(blockNum, limit int) ([]containerRecord, error) {
+	cr.mu.Lock()
+	format := cr.format
+	cr.mu.Unlock()
+
+	return cr.index.getBlock(cr.FileReader, blockNum, limit, func(lines []string, first int) []containerRecord {
+		records := make([]containerRecord, 0, indexStride)
+		var current containerRecord
+		joining := false
+		for _, line := range lines {
+			record, partial := parseContainerLine(format, line)
+			if !joining {
+				current = record
+			} else if len(current.text) < limit {
+				current.text += record.text
+			}
+			joining = partial
+			if !partial {
+				records = append(records, current)
+			}
+		}
+		if joining {
+			records = append(records, current)
+		}
+		return records
+	})
+}
+
+// Tail returns the last n records. Records split across more lines
+// than the last n are cut at the start.
+func (cr *ContainerLogReader) Tail(n int) ([]string, error) {
+	lines, err := cr.FileReader.Tail(n)
+	if err != nil || !cr.decoding() {
+		return lines, err
+	}
+
+	cr.mu.Lock()
+	format, hidePrefix := cr.format, cr.hidePrefix
+	cr.mu.Unlock()
+
+	var records []string
+	var current containerRecord
+	joining := false
+	for _, line := range lines {
+		record, partial := parseContainerLine(format, line)
+		if joining {
+			current.text += record.text
+		} else {
+			current = record
+		}
+		joining = partial
+		if !partial {
+			records = append(records, formatRecord(current, hidePrefix))
+		}
+	}
+	return records, nil
+}
+
+// TogglePrefix shows or hides the time and stream before each message.
+// It returns false for files that are not container logs.
+func (cr *ContainerLogReader) TogglePrefix() bool {
+	if !cr.decoding() {
+		return false
+	}
+
+	cr.mu.Lock()
+	cr.hidePrefix = !cr.hidePrefix
+	cr.mu.Unlock()
+
+	return true
+}
+
+// Gutter marks messages written to stderr
+func (cr *ContainerLogReader) Gutter(index int) string {
+	if !cr.decoding() {
+		return cr.FileReader.Gutter(index)
+	}
+	if index < 0 || index >= cr.LineCount() {
+		return ""
+	}
+
+	records, err := cr.getBlock(index/indexStride, 0)
+	if err != nil || index%indexStride >= len(records) || !records[index%indexStride].stderr {
+		return ""
+	}
+	return stderrMarker
+}
+
+// HasGutter reports whether the file is a container log or a multiplexed
+// Docker stream, both telling stdout from stderr
+func (cr *ContainerLogReader) HasGutter() bool {
+	return cr.decoding() || cr.FileReader.HasGutter()
+}
+
+// Filename returns the name of the file, with the log format if decoded
+func (cr *ContainerLogReader) Filename() string {
+	cr.mu.Lock()
+	format := cr.format
+	cr.mu.Unlock()
+
+	switch format {
+	case formatDocker:
+		return cr.FileReader.Filename() + " [docker]"
+	case formatCRI:
+		return cr.FileReader.Filename() + " [cri]"
+	}
+	return cr.FileReader.Filename()
+}
+
+// Close stops scanning and closes the file
+func (cr *ContainerLogReader) Close() error {
+	if !cr.index.close() {
+		return nil
+	}
+	return cr.FileReader.Close()
+}
@@ -0,0 +1,45 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestContainerLogGrowingLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "container.log")
+	first := `{"log":"a\n","stream":"stdout","time":"2024-01-02T00:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(first+`{"log":"b`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFileReader(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.Follow(true)
+	cr := NewContainerLogReader(fr)
+	defer cr.Close()
+	if err := cr.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A half written line is not a message yet
+	waitFor(t, "the unterminated line", func() bool {
+		line, err := cr.GetLine(1)
+		return cr.decoding() && err == nil && line == `{"log":"b`
+	})
+
+	appendFile(t, path, `\n","stream":"stderr","time":"2024-01-02T00:00:01Z"}`+"\n")
+	want := []string{
+		"\x1b[2m2024-01-02T00:00:00Z stdout\x1b[0m a",
+		"\x1b[2m2024-01-02T00:00:01Z stderr\x1b[0m b",
+	}
+	waitFor(t, "the decoded message", func() bool {
+		lines, err := cr.GetLines(0, 2)
+		return err == nil && reflect.DeepEqual(lines, want)
+	})
+	if marker := cr.Gutter(1); marker != stderrMarker {
+		t.Errorf("Gutter(1) = %q, want the stderr marker", marker)
+	}
+}
@@ -0,0 +1,87 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// compression returns the compression format of content starting with
+// the given bytes, or "" if it is not compressed
+func compression(header []byte) string {
+	switch {
+	case len(header) >= 2 && header[0] == 0x1f && header[1] == 0x8b:
+		return "gzip"
+	case len(header) >= 4 && bytes.HasPrefix(header, []byte("BZh")) && header[3] >= '1' && header[3] <= '9':
+		return "bzip2"
+	case isZlib(header):
+		return "zlib"
+	}
+	return ""
+}
+
+// partialMagic reports whether header is too short to tell but may be
+// the start of a magic number
+func partialMagic(header []byte) bool {
+	switch {
+	case len(header) == 0:
+		return true
+	case len(header) < 2:
+		return header[0] == 0x1f || header[0] == 'B' || header[0] == 0x78
+	case len(header) < 4:
+		return bytes.HasPrefix([]byte("BZh"), header)
+	}
+	return false
+}
+
+// isZlib checks for a zlib header. The header is only two bytes and
+// can occur in text, so the start of the data must also decompress.
+func isZlib(header []byte) bool {
+	if len(header) < 2 || header[0] != 0x78 || (int(header[0])<<8|int(header[1]))%31 != 0 {
+		return false
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(header))
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+
+	_, err = zr.Read(make([]byte, 1))
+	return err == nil || err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// decompress detects compressed content by its magic bytes and returns a
+// reader of the decompressed data, or of the data as is
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	// Wait for more than has arrived only while it may be the start of a
+	// magic number, a slow pipe may not send more for a while
+	var header []byte
+	for {
+		header, _ = br.Peek(br.Buffered())
+		if !partialMagic(header) {
+			break
+		}
+		if _, err := br.Peek(len(header) + 1); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	header, _ = br.Peek(br.Buffered())
+
+	switch compression(header) {
+	case "gzip":
+		return gzip.NewReader(br)
+	case "bzip2":
+		return bzip2.NewReader(br), nil
+	case "zlib":
+		return zlib.NewReader(br)
+	}
+	return br, nil
+}
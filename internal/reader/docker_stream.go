@@ -0,0 +1,109 @@
+package reader
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// muxHeaderSize is the size of the frame header of multiplexed Docker
+	// streams: the stream, three zero bytes and the big endian frame size
+	muxHeaderSize = 8
+
+	// muxStderr is the stream number of stderr frames
+	muxStderr = 2
+)
+
+// stderrMarker is the gutter shown next to lines written to stderr
+const stderrMarker = "\x1b[31m▌\x1b[0m"
+
+// isMultiplexed reports whether content starting with sample is a
+// multiplexed stdout/stderr stream, as sent by the Docker API and
+// docker attach when no TTY is allocated. The sample must be a chain of
+// frames, each header followed by as many bytes as it announces, up to
+// where the sample ends.
+func isMultiplexed(sample []byte) bool {
+	if len(sample) < muxHeaderSize {
+		return false
+	}
+
+	var pos int64
+	for pos < int64(len(sample)) {
+		header := sample[pos:min(pos+muxHeaderSize, int64(len(sample)))]
+		if !validMuxHeader(header) {
+			return false
+		}
+		if len(header) < muxHeaderSize {
+			break // The sample ends within a header
+		}
+		pos += muxHeaderSize + int64(binary.BigEndian.Uint32(header[4:]))
+	}
+	return true
+}
+
+// validMuxHeader reports whether header, possibly cut short, can start a
+// frame: a stream number below 3, three zero bytes and a size other than
+// zero
+func validMuxHeader(header []byte) bool {
+	if header[0] > muxStderr {
+		return false
+	}
+	for _, b := range header[1:min(len(header), 4)] {
+		if b != 0 {
+			return false
+		}
+	}
+	return len(header) < muxHeaderSize || binary.BigEndian.Uint32(header[4:]) > 0
+}
+
+// muxFrame is where a frame starts in the demultiplexed output
+type muxFrame struct {
+	offset int64
+	stream byte
+}
+
+// muxReader removes the frame headers of a multiplexed stream, keeping
+// track of the stream each part of the output came from
+type muxReader struct {
+	r         io.Reader
+	header    [muxHeaderSize]byte
+	remaining int        // Bytes left in the current frame
+	offset    int64      // Bytes output so far
+	frames    []muxFrame // Frames not yet passed by streamAt
+}
+
+// newMuxReader creates a reader demultiplexing r
+func newMuxReader(r io.Reader) *muxReader {
+	return &muxReader{r: r}
+}
+
+// Read implements io.Reader
+func (m *muxReader) Read(p []byte) (int, error) {
+	for m.remaining == 0 {
+		if _, err := io.ReadFull(m.r, m.header[:]); err != nil {
+			return 0, err
+		}
+		m.remaining = int(binary.BigEndian.Uint32(m.header[4:]))
+		m.frames = append(m.frames, muxFrame{offset: m.offset, stream: m.header[0]})
+	}
+
+	n, err := m.r.Read(p[:min(len(p), m.remaining)])
+	m.remaining -= n
+	m.offset += int64(n)
+	if err == io.EOF && m.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// streamAt returns the stream of the output byte at offset. Offsets must
+// be asked for in increasing order, earlier frames are forgotten.
+func (m *muxReader) streamAt(offset int64) byte {
+	for len(m.frames) > 1 && m.frames[1].offset <= offset {
+		m.frames = m.frames[1:]
+	}
+	if len(m.frames) == 0 {
+		return 0
+	}
+	return m.frames[0].stream
+}
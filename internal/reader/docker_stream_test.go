@@ -0,0 +1,104 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// muxFrames builds a multiplexed stream from stream numbers and payloads
+func muxFrames(frames ...any) []byte {
+	var buf bytes.Buffer
+	for i := 0; i+1 < len(frames); i += 2 {
+		payload := frames[i+1].(string)
+		header := make([]byte, muxHeaderSize)
+		header[0] = byte(frames[i].(int))
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		buf.Write(header)
+		buf.WriteString(payload)
+	}
+	return buf.Bytes()
+}
+
+func TestIsMultiplexed(t *testing.T) {
+	stream := muxFrames(1, "out\n", 2, "err\n", 1, "more\n")
+
+	tests := []struct {
+		name   string
+		sample []byte
+		want   bool
+	}{
+		{"frames", stream, true},
+		{"cut in a payload", stream[:len(stream)-2], true},
+		{"cut in a header", stream[:muxHeaderSize+4+3], true},
+		{"single header", stream[:muxHeaderSize], true},
+		{"shorter than a header", stream[:muxHeaderSize-1], false},
+		{"text", []byte("hello world\n"), false},
+		{"NUL bytes", append(make([]byte, 64), "data"...), false},
+		{"empty frame", muxFrames(1, ""), false},
+		{"bad stream number", muxFrames(3, "out\n"), false},
+		{"garbage after a frame", append(muxFrames(1, "out\n"), "garbage"...), false},
+		{"bad header after a frame", append(muxFrames(1, "out\n"), 1, 0, 0, 1, 0, 0, 0, 4), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMultiplexed(tt.sample); got != tt.want {
+				t.Errorf("isMultiplexed(%q) = %v, want %v", tt.sample, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuxReader(t *testing.T) {
+	stream := muxFrames(1, "out 1\nout", 2, " err\n", 1, "out 2\n")
+	mux := newMuxReader(bytes.NewReader(stream))
+
+	// Read in small pieces to cross frame boundaries
+	var out []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := mux.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if want := "out 1\nout err\nout 2\n"; string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+
+	// The stream each line starts in
+	streams := []struct {
+		offset int64
+		want   byte
+	}{
+		{0, 1},
+		{6, 1},
+		{9, 2},
+		{13, 2},
+		{14, 1},
+	}
+	for _, s := range streams {
+		if got := mux.streamAt(s.offset); got != s.want {
+			t.Errorf("streamAt(%d) = %d, want %d", s.offset, got, s.want)
+		}
+	}
+}
+
+func TestMuxReaderTruncated(t *testing.T) {
+	stream := muxFrames(1, "complete\n", 1, "cut short\n")
+	mux := newMuxReader(bytes.NewReader(stream[:len(stream)-3]))
+
+	out, err := io.ReadAll(mux)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if !strings.HasPrefix(string(out), "complete\ncut sh") {
+		t.Errorf("output = %q", out)
+	}
+}
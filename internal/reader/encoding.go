@@ -0,0 +1,230 @@
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Supported character encodings
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1"
+)
+
+// encodingAliases maps accepted encoding names to supported encodings
+var encodingAliases = map[string]string{
+	"utf-8":      EncodingUTF8,
+	"utf8":       EncodingUTF8,
+	"utf-16le":   EncodingUTF16LE,
+	"utf16le":    EncodingUTF16LE,
+	"utf-16be":   EncodingUTF16BE,
+	"utf16be":    EncodingUTF16BE,
+	"latin-1":    EncodingLatin1,
+	"latin1":     EncodingLatin1,
+	"iso-8859-1": EncodingLatin1,
+	"iso8859-1":  EncodingLatin1,
+}
+
+// encodingSampleSize is the number of leading bytes used to detect the encoding
+const encodingSampleSize = 4096
+
+// minUTF16Pairs is the number of byte pairs needed to recognize UTF-16
+// without a byte order mark. Fewer, such as a few names separated by NUL
+// bytes, are too easily mistaken for it.
+const minUTF16Pairs = 16
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files
+const utf8BOM = "\xef\xbb\xbf"
+
+// normalizeEncoding returns the supported encoding for a user given name,
+// or "" for an empty name, meaning the encoding is detected
+func normalizeEncoding(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	enc, ok := encodingAliases[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding %q", name)
+	}
+	return enc, nil
+}
+
+// detectEncoding guesses the encoding of content starting with sample.
+// A byte order mark decides; otherwise UTF-16 is recognized by its NUL
+// bytes and content that is not valid UTF-8 is taken as Latin-1.
+func detectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte(utf8BOM)):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		return EncodingUTF16BE
+	}
+
+	// ASCII text in UTF-16 has a NUL in every other byte
+	var evenNULs, oddNULs int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNULs++
+		} else {
+			oddNULs++
+		}
+	}
+	pairs := len(sample) / 2
+	if pairs >= minUTF16Pairs {
+		if oddNULs*10 > pairs*3 && evenNULs*20 < pairs {
+			return EncodingUTF16LE
+		}
+		if evenNULs*10 > pairs*3 && oddNULs*20 < pairs {
+			return EncodingUTF16BE
+		}
+	}
+
+	// A sample cut from a longer file may end in the middle of a character
+	if len(sample) >= encodingSampleSize {
+		sample = trimPartialRune(sample)
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of data
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= max(len(data)-utf8.UTFMax, 0); i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			return data[:i]
+		}
+		break
+	}
+	return data
+}
+
+// isUTF16 reports whether an encoding uses two bytes per code unit, so
+// its lines cannot be found by looking for newline bytes
+func isUTF16(enc string) bool {
+	return enc == EncodingUTF16LE || enc == EncodingUTF16BE
+}
+
+// lineDecoder returns the function converting lines in a byte oriented
+// encoding to UTF-8
+func lineDecoder(enc string) func(string) string {
+	if enc == EncodingLatin1 {
+		return decodeLatin1
+	}
+	return func(line string) string {
+		return strings.TrimPrefix(line, utf8BOM)
+	}
+}
+
+// decodeLatin1 converts an ISO-8859-1 line to UTF-8
+func decodeLatin1(line string) string {
+	ascii := true
+	for i := 0; i < len(line); i++ {
+		if line[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return line
+	}
+
+	buf := make([]byte, 0, len(line)*2)
+	for i := 0; i < len(line); i++ {
+		buf = utf8.AppendRune(buf, rune(line[i]))
+	}
+	return string(buf)
+}
+
+// utf16Reader transcodes a UTF-16 stream to UTF-8, dropping a leading
+// byte order mark
+type utf16Reader struct {
+	r         io.Reader
+	bigEndian bool
+	buf       []byte
+	in        []byte // Input not decoded yet
+	out       []byte // Decoded output not returned yet
+	started   bool
+}
+
+// newUTF16Reader creates a reader decoding UTF-16 in the given encoding
+func newUTF16Reader(r io.Reader, enc string) *utf16Reader {
+	return &utf16Reader{
+		r:         r,
+		bigEndian: enc == EncodingUTF16BE,
+		buf:       make([]byte, 32*1024),
+	}
+}
+
+// Read implements io.Reader
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		n, err := u.r.Read(u.buf)
+		u.in = append(u.in, u.buf[:n]...)
+		u.decode(err != nil)
+
+		if err != nil && len(u.out) == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// decode converts the complete code units of the input. At the end of
+// the input, a lone surrogate is replaced and an odd byte dropped.
+func (u *utf16Reader) decode(final bool) {
+	unit := func(i int) rune {
+		if u.bigEndian {
+			return rune(u.in[i])<<8 | rune(u.in[i+1])
+		}
+		return rune(u.in[i+1])<<8 | rune(u.in[i])
+	}
+
+	i := 0
+	for i+1 < len(u.in) {
+		r := unit(i)
+		size := 2
+
+		if utf16.IsSurrogate(r) {
+			if i+3 < len(u.in) {
+				r = utf16.DecodeRune(r, unit(i+2))
+				if r != utf8.RuneError {
+					size = 4
+				}
+			} else if !final {
+				break
+			} else {
+				r = utf8.RuneError
+			}
+		}
+
+		if u.started || r != 0xfeff {
+			u.out = utf8.AppendRune(u.out, r)
+		}
+		u.started = true
+		i += size
+	}
+
+	u.in = append(u.in[:0], u.in[i:]...)
+	if final {
+		u.in = u.in[:0]
+	}
+}
@@ -0,0 +1,70 @@
+package reader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	// A sample as long as those cut from a file, ending in the first two
+	// bytes of a three-byte character
+	cut := []byte(strings.Repeat("a", encodingSampleSize-2) + "\xe2\x82")
+
+	tests := []struct {
+		name   string
+		sample []byte
+		want   string
+	}{
+		{"empty", nil, EncodingUTF8},
+		{"ascii", []byte("hello\n"), EncodingUTF8},
+		{"utf-8", []byte("café\n"), EncodingUTF8},
+		{"utf-8 bom", []byte("\xef\xbb\xbfhello\n"), EncodingUTF8},
+		{"utf-16le bom", []byte("\xff\xfeh\x00i\x00"), EncodingUTF16LE},
+		{"utf-16be bom", []byte("\xfe\xff\x00h\x00i"), EncodingUTF16BE},
+		{"utf-16le", []byte(strings.Repeat("h\x00e\x00l\x00l\x00o\x00\n\x00", 3)), EncodingUTF16LE},
+		{"utf-16be", []byte(strings.Repeat("\x00h\x00e\x00l\x00l\x00o\x00\n", 3)), EncodingUTF16BE},
+		{"short NUL separated", []byte("a\x00b b\x00c"), EncodingUTF8},
+		{"latin-1", []byte("caf\xe9 au lait\n"), EncodingLatin1},
+		{"latin-1 at end", []byte("caf\xe9\n"), EncodingLatin1},
+		{"latin-1 last byte", []byte("caf\xe9"), EncodingLatin1},
+		{"cut sample", cut, EncodingUTF8},
+		{"cut sample after invalid byte", append([]byte("\xe9"), cut[1:]...), EncodingLatin1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectEncoding(tt.sample); got != tt.want {
+				t.Errorf("detectEncoding(%q) = %q, want %q", truncateSample(tt.sample), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"ab\xe2\x82\xac", "ab\xe2\x82\xac"},
+		{"ab\xe2\x82", "ab"},
+		{"ab\xe2", "ab"},
+		{"ab\xf0\x9f\x98", "ab"},
+		{"ab\xe9\n", "ab\xe9\n"},
+		{"ab\x82", "ab\x82"},
+	}
+	for _, tt := range tests {
+		if got := trimPartialRune([]byte(tt.data)); !bytes.Equal(got, []byte(tt.want)) {
+			t.Errorf("trimPartialRune(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+// truncateSample shortens a sample for error messages
+func truncateSample(sample []byte) []byte {
+	if len(sample) > 32 {
+		return sample[:32]
+	}
+	return sample
+}
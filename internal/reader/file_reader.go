@@ -2,107 +2,843 @@ package reader
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"io"
+	"math"
 	"os"
+	"sync"
+	"time"
 )
 
-// FileReader reads a file line by line with buffering
+const (
+	// indexChunkSize is the number of bytes read per indexing step
+	indexChunkSize = 256 * 1024
+
+	// notifyInterval limits how often indexing progress is reported
+	notifyInterval = 100 * time.Millisecond
+
+	// followInterval is how often a followed file is checked for growth
+	followInterval = 250 * time.Millisecond
+)
+
+// segment is one indexed file, or a marker line separating the files
+// seen while following a rotated log. A file truncated in place keeps
+// its lines, though only those still cached can be shown.
+type segment struct {
+	file   *os.File // nil for a marker line or truncated content
+	index  *lineIndex
+	marker string
+	kept   map[int]*block // Blocks of truncated content still in memory
+
+	last    *block // Last incomplete block read
+	lastNum int
+}
+
+// lines returns the number of lines in the segment
+func (s *segment) lines() int {
+	if s.index == nil {
+		return 1
+	}
+	return s.index.lines()
+}
+
+// blockKey identifies a cached block of lines
+type blockKey struct {
+	seg   *segment
+	block int
+}
+
+// FileReader provides line access to a file.
+// Regular files are indexed in the background and lines are read on
+// demand by seeking, so only the visible part of the file is in memory.
+// Streams such as stdin cannot be seeked and are read into memory, or
+// into a temporary file indexed the same way once they outgrow the
+// memory budget.
 type FileReader struct {
-	file     *io.ReadCloser
-	lines    []string
+	mu       sync.Mutex
+	segments []*segment          // Indexed files, the last one is being read
+	stream   io.ReadCloser       // Non-seekable input, nil when reading a file
+	cache    *pageCache          // Recently used blocks of indexStride lines, shared
+	lines    []string            // Stream content, until spilled to a file
+	split    *splitter           // How the content is split into lines
+	encoding string              // Forced or detected character encoding
+	decode   func(string) string // Converts lines to UTF-8 as they are read
+	binary   bool                // Content looks like binary data
+	crlf     bool                // Lines end in CRLF, stripped when read
+	open     bool                // The last stream line read has no delimiter
+	muxed    bool                // Docker stream with stdout/stderr frames
+	stderr   []uint64            // Stream lines from stderr, one bit per line
+	saved    int64               // Size covered by the index in the cache directory
 	loaded   bool
+	streamed bool // The whole stream has been read
+	follow   bool
+	byName   bool
+	err      error
+	updates  chan struct{}
+	wake     chan struct{}
+	done     chan struct{}
+	path     string
 	filename string
 }
 
-// NewFileReader creates a new file reader
-func NewFileReader(filename string) (*FileReader, error) {
-	var file io.ReadCloser
+// Options control how a file is read
+type Options struct {
+	// Encoding is the character encoding of the file; empty to detect it
+	Encoding string
 
-	if filename == "-" || filename == "" {
-		file = os.Stdin
-		filename = "stdin"
-	} else {
-		f, err := os.Open(filename)
-		if err != nil {
-			return nil, err
-		}
-		file = f
+	// Delimiter ends each line instead of a newline, e.g. "\x00"
+	Delimiter string
+
+	// RecordLength splits the content into lines of this many bytes,
+	// without delimiters; zero to split on delimiters
+	RecordLength int
+
+	// Tee receives a copy of stream input as it is read, if not nil
+	Tee io.Writer
+
+	// MaxMemory is the number of bytes of lines kept in memory for all
+	// indexed files together, and of each stream before it is moved to a
+	// temporary file; zero for DefaultMaxMemory
+	MaxMemory int64
+}
+
+// newFileReader creates a reader with nothing to read yet
+func newFileReader(filename string, opts Options) (*FileReader, error) {
+	encoding, err := normalizeEncoding(opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	split, err := newSplitter(opts.Delimiter, opts.RecordLength)
+	if err != nil {
+		return nil, err
 	}
 
 	return &FileReader{
-		file:     &file,
+		cache:    sharedPageCache(opts.MaxMemory),
+		split:    split,
 		lines:    make([]string, 0),
-		loaded:   false,
+		updates:  make(chan struct{}, 1),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		encoding: encoding,
+		path:     filename,
 		filename: filename,
 	}, nil
 }
 
-// Load reads all lines from the file into memory
+// NewStreamReader creates a reader for content that can only be read
+// once, from start to end
+func NewStreamReader(name string, stream io.ReadCloser, opts Options) (*FileReader, error) {
+	fr, err := newFileReader(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	fr.stream = stream
+	if opts.Tee != nil {
+		fr.stream = teeStream{Reader: io.TeeReader(stream, opts.Tee), Closer: stream}
+	}
+	return fr, nil
+}
+
+// teeStream is a stream copied to a writer as it is read
+type teeStream struct {
+	io.Reader
+	io.Closer
+}
+
+// NewFileReader creates a new file reader
+func NewFileReader(filename string, opts Options) (*FileReader, error) {
+	if filename == "-" || filename == "" {
+		return NewStreamReader("stdin", os.Stdin, opts)
+	}
+
+	fr, err := newFileReader(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Pipes and devices (e.g. <(cmd)) cannot be indexed. Neither can
+	// compressed files or UTF-16, which are decoded as a stream.
+	if !info.Mode().IsRegular() {
+		fr.stream = f
+		return fr, nil
+	}
+
+	// Compressed content is only detected once decompressed
+	sample := readSample(f)
+	if compression(sample) != "" || isMultiplexed(sample) {
+		fr.stream = f
+		return fr, nil
+	}
+	// Records split on other delimiters may well contain NUL bytes
+	if fr.encoding == "" {
+		fr.encoding = detectEncoding(sample)
+		fr.binary = !isUTF16(fr.encoding) && fr.split.newline() && isBinary(sample)
+	}
+	if isUTF16(fr.encoding) {
+		fr.stream = f
+		return fr, nil
+	}
+
+	// A saved index spares reading the file again, or all but its new tail
+	index := loadIndex(f, filename, fr.split)
+	if index == nil {
+		index = newLineIndex(fr.split)
+	}
+	fr.saved = index.size
+
+	fr.segments = []*segment{{file: f, index: index}}
+	fr.decode = lineDecoder(fr.encoding)
+	fr.crlf = fr.split.newline() && bytes.Contains(sample, []byte("\r\n"))
+
+	return fr, nil
+}
+
+// Load prepares the file for reading. Regular files are indexed and
+// streams are read into memory, both in the background, so Load returns
+// immediately and lines become available as they arrive.
 func (fr *FileReader) Load() error {
+	fr.mu.Lock()
 	if fr.loaded {
+		fr.mu.Unlock()
 		return nil
 	}
+	fr.loaded = true
+	fr.mu.Unlock()
 
-	scanner := bufio.NewScanner(*fr.file)
-	// Increase buffer size for long lines
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024) // Max 1MB per line
+	if fr.stream == nil {
+		go fr.buildIndex()
+	} else {
+		go fr.readStream()
+	}
+	return nil
+}
 
-	for scanner.Scan() {
-		fr.lines = append(fr.lines, scanner.Text())
+// readSample reads the start of a file, used to detect its format
+func readSample(f *os.File) []byte {
+	sample := make([]byte, encodingSampleSize)
+	n, _ := f.ReadAt(sample, 0)
+	return sample[:n]
+}
+
+// readStream reads the stream line by line until EOF, decompressing and
+// transcoding it to UTF-8 if needed. Lines of any length are read in
+// chunks, so a single huge line does not fail the load. Once the lines
+// outgrow the memory budget, they are moved to a temporary file.
+func (fr *FileReader) readStream() {
+	br, mux, decode, err := fr.decodeStream()
+	if err != nil {
+		fr.mu.Lock()
+		fr.streamed = true
+		fr.err = err
+		fr.mu.Unlock()
+
+		fr.notify()
+		return
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
+	// Lines are kept as read and decoded when requested, so they can be
+	// moved to a file unchanged
+	fr.mu.Lock()
+	fr.decode = decode
+	fr.mu.Unlock()
+
+	lastNotify := time.Now()
+	var offset int64
+	var count int  // Lines read so far
+	var size int64 // Memory used by the lines kept in memory
+	var spilled *spill
+	canSpill := true
+	for {
+		line, length, err := fr.split.readRecord(br, math.MaxInt)
+		if length > 0 {
+			text := fr.split.trim(string(line))
+
+			fr.mu.Lock()
+			if mux != nil && mux.streamAt(offset) == muxStderr {
+				for len(fr.stderr) <= count/64 {
+					fr.stderr = append(fr.stderr, 0)
+				}
+				fr.stderr[count/64] |= 1 << (count % 64)
+			}
+			if spilled == nil {
+				fr.lines = append(fr.lines, text)
+				size += int64(len(text)) + stringOverhead
+			}
+			if !fr.crlf && fr.split.newline() && bytes.HasSuffix(line, []byte("\r\n")) {
+				fr.crlf = true
+			}
+			fr.open = !bytes.HasSuffix(line, fr.split.delim)
+			fr.mu.Unlock()
+
+			if spilled != nil {
+				spilled.add(text)
+			}
+			count++
+		}
+		offset += int64(length)
+
+		if spilled == nil && canSpill && size > fr.cache.budget {
+			var spillErr error
+			if spilled, spillErr = fr.spillStream(); spillErr != nil {
+				canSpill = false // Keep the lines in memory
+			}
+		}
+
+		// Write when the input pauses, so the lines show up without delay
+		if spilled != nil && (err != nil || br.Buffered() == 0 || len(spilled.pending) >= indexChunkSize) {
+			if flushErr := fr.flushSpill(spilled); flushErr != nil && err == nil {
+				err = flushErr
+			}
+		}
+
+		if err != nil {
+			fr.mu.Lock()
+			fr.streamed = true
+			if spilled != nil {
+				spilled.seg.index.eof = true // Count a last line without delimiter
+			}
+			if err != io.EOF {
+				fr.err = err
+			}
+			fr.mu.Unlock()
+			break
+		}
+
+		// Also when the input pauses, so the end of a burst is not held back
+		if time.Since(lastNotify) >= notifyInterval || br.Buffered() == 0 {
+			fr.notify()
+			lastNotify = time.Now()
+		}
 	}
 
-	fr.loaded = true
-	return nil
+	fr.notify()
 }
 
-// GetLine returns the line at the specified index (0-based)
-func (fr *FileReader) GetLine(index int) (string, error) {
-	if !fr.loaded {
-		if err := fr.Load(); err != nil {
-			return "", err
+// decodeStream sets up decompression, removal of Docker stream frame
+// headers and character decoding of the stream. It returns the reader to
+// split into lines, the frame reader if the stream is multiplexed and the
+// function converting each line to UTF-8.
+func (fr *FileReader) decodeStream() (*bufio.Reader, *muxReader, func(string) string, error) {
+	r, err := decompress(fr.stream)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	// A frame header starts with a stream number below 3
+	var mux *muxReader
+	if head, err := br.Peek(1); err != nil && err != io.EOF {
+		return nil, nil, nil, err
+	} else if len(head) > 0 && head[0] <= muxStderr {
+		br.Peek(muxHeaderSize)
+		if sample, _ := br.Peek(br.Buffered()); isMultiplexed(sample) {
+			mux = newMuxReader(br)
+			br = bufio.NewReaderSize(mux, 64*1024)
+
+			fr.mu.Lock()
+			fr.muxed = true
+			fr.mu.Unlock()
 		}
 	}
 
-	if index < 0 || index >= len(fr.lines) {
+	fr.mu.Lock()
+	encoding := fr.encoding
+	fr.mu.Unlock()
+
+	if encoding == "" {
+		// Detect from what has arrived, a slow pipe may not send more for a while
+		if _, err := br.Peek(1); err != nil && err != io.EOF {
+			return nil, nil, nil, err
+		}
+		sample, _ := br.Peek(min(br.Buffered(), encodingSampleSize))
+		encoding = detectEncoding(sample)
+
+		fr.mu.Lock()
+		fr.encoding = encoding
+		fr.binary = !isUTF16(encoding) && fr.split.newline() && isBinary(sample)
+		fr.mu.Unlock()
+	}
+
+	if isUTF16(encoding) {
+		br = bufio.NewReaderSize(newUTF16Reader(br, encoding), 64*1024)
+		return br, mux, lineDecoder(EncodingUTF8), nil
+	}
+	return br, mux, lineDecoder(encoding), nil
+}
+
+// buildIndex scans the file for line breaks. At EOF it waits for the
+// file to grow while following, and stops once Close is called.
+func (fr *FileReader) buildIndex() {
+	buf := make([]byte, indexChunkSize)
+	lastNotify := time.Now()
+
+	for {
+		select {
+		case <-fr.done:
+			return
+		default:
+		}
+
+		n, err := fr.readChunk(fr.current(), buf)
+
+		if err == io.EOF {
+			if n > 0 || time.Since(lastNotify) >= notifyInterval {
+				fr.notify()
+				lastNotify = time.Now()
+			}
+			if n == 0 && fr.Following() && fr.checkRotation(buf) {
+				continue
+			}
+			fr.saveIndex()
+			if !fr.waitForData() {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			fr.notify()
+			return
+		}
+
+		if time.Since(lastNotify) >= notifyInterval {
+			fr.notify()
+			lastNotify = time.Now()
+		}
+	}
+}
+
+// saveIndex stores the index in the cache directory once it has grown by
+// enough to be worth it. Only the file as opened is saved, not the files
+// replacing it while following.
+func (fr *FileReader) saveIndex() {
+	fr.mu.Lock()
+	seg := fr.segments[0]
+	rotated := len(fr.segments) > 1
+	fr.mu.Unlock()
+
+	// Only the indexing goroutine modifies the index, so it can be read unlocked
+	if rotated || seg.index.size-fr.saved < minCachedIndexSize {
+		return
+	}
+	saveIndex(seg.file, fr.path, seg.index)
+	fr.saved = seg.index.size
+}
+
+// readChunk indexes the next chunk of a segment's file
+func (fr *FileReader) readChunk(seg *segment, buf []byte) (int, error) {
+	// Only the indexing goroutine modifies the index, so size can be read unlocked
+	n, err := seg.file.ReadAt(buf, seg.index.size)
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	seg.index.add(buf[:n])
+	seg.index.eof = err == io.EOF
+	if err != nil && err != io.EOF {
+		fr.err = err
+	}
+
+	return n, err
+}
+
+// current returns the segment being indexed
+func (fr *FileReader) current() *segment {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.segments[len(fr.segments)-1]
+}
+
+// waitForData blocks at EOF until it is worth checking the file for new
+// data again. It returns false once the reader is closed.
+func (fr *FileReader) waitForData() bool {
+	var poll <-chan time.Time
+	if fr.Following() {
+		poll = time.After(followInterval)
+	}
+
+	select {
+	case <-fr.done:
+		return false
+	case <-fr.wake:
+	case <-poll:
+	}
+	return true
+}
+
+// Follow enables or disables following the file as it grows
+func (fr *FileReader) Follow(enabled bool) {
+	fr.mu.Lock()
+	fr.follow = enabled
+	fr.mu.Unlock()
+
+	select {
+	case fr.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Following reports whether the file is being followed
+func (fr *FileReader) Following() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.follow
+}
+
+// SetFollowByName makes following track the file name instead of the
+// open file, so a log replaced by rotation is reopened
+func (fr *FileReader) SetFollowByName(enabled bool) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.byName = enabled
+}
+
+// notify signals that the line count changed without blocking
+func (fr *FileReader) notify() {
+	select {
+	case fr.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Updates returns a channel that receives a value whenever new lines
+// become available
+func (fr *FileReader) Updates() <-chan struct{} {
+	return fr.updates
+}
+
+// Loading reports whether the file is still being read or indexed
+func (fr *FileReader) Loading() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.err != nil {
+		return false
+	}
+	if fr.stream != nil {
+		return !fr.streamed
+	}
+	return !fr.segments[len(fr.segments)-1].index.eof
+}
+
+// Err returns the error that stopped background indexing, if any
+func (fr *FileReader) Err() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.err
+}
+
+// GetLine returns the line at the specified index (0-based)
+func (fr *FileReader) GetLine(index int) (string, error) {
+	lines, err := fr.GetLines(index, index+1)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
 		return "", errors.New("line index out of bounds")
 	}
 
-	return fr.lines[index], nil
+	return lines[0], nil
 }
 
 // GetLines returns a range of lines [start, end)
 func (fr *FileReader) GetLines(start, end int) ([]string, error) {
-	if !fr.loaded {
+	return fr.getLines(start, end, math.MaxInt)
+}
+
+// GetLinePrefixes returns a range of lines [start, end), reading no more
+// than limit bytes of lines too long to be cached
+func (fr *FileReader) GetLinePrefixes(start, end, limit int) ([]string, error) {
+	return fr.getLines(start, end, limit)
+}
+
+// getLines returns a range of lines, long lines cut to limit bytes
+func (fr *FileReader) getLines(start, end, limit int) ([]string, error) {
+	if !fr.isLoaded() {
 		if err := fr.Load(); err != nil {
 			return nil, err
 		}
 	}
 
+	total := fr.LineCount()
 	if start < 0 {
 		start = 0
 	}
-	if end > len(fr.lines) {
-		end = len(fr.lines)
+	if end > total {
+		end = total
 	}
 	if start >= end {
 		return []string{}, nil
 	}
 
-	return fr.lines[start:end], nil
+	fr.mu.Lock()
+	if fr.segments == nil {
+		defer fr.mu.Unlock()
+
+		result := make([]string, end-start)
+		for i, line := range fr.lines[start:end] {
+			result[i] = fr.decode(line)
+		}
+		return result, nil
+	}
+	segments := fr.segments
+	fr.mu.Unlock()
+
+	result := make([]string, 0, end-start)
+	first := 0
+	for _, seg := range segments {
+		if len(result) == end-start {
+			break
+		}
+
+		fr.mu.Lock()
+		count := seg.lines()
+		fr.mu.Unlock()
+
+		if start+len(result) < first+count {
+			lines, err := fr.segmentLines(seg, start+len(result)-first, end-first, limit)
+			result = append(result, lines...)
+			if err != nil {
+				return result, err
+			}
+		}
+		first += count
+	}
+
+	return result, nil
+}
+
+// segmentLines returns the lines [start, end) of a segment, long lines
+// cut to limit bytes
+func (fr *FileReader) segmentLines(seg *segment, start, end, limit int) ([]string, error) {
+	if seg.index == nil {
+		return []string{seg.marker}, nil
+	}
+	if seg.file == nil {
+		return truncatedLines(seg, start, end), nil
+	}
+
+	result := make([]string, 0, end-start)
+	for blockNum := start / indexStride; len(result) < end-start; blockNum++ {
+		b, err := fr.getBlock(seg, blockNum)
+		if err != nil {
+			return result, err
+		}
+
+		first := blockNum * indexStride
+		from := max(start-first, 0)
+		to := min(end-first, len(b.lines))
+		if from >= to {
+			break
+		}
+
+		for i := from; i < to; i++ {
+			ref, long := b.long[i]
+			if !long {
+				result = append(result, b.lines[i])
+				continue
+			}
+
+			line, err := readLongLine(seg.file, ref, seg.index.split, limit)
+			if err != nil {
+				return result, err
+			}
+			result = append(result, fr.decode(line))
+		}
+	}
+
+	return result, nil
+}
+
+// getBlock returns the given block, reading it from the file if it is
+// not cached
+func (fr *FileReader) getBlock(seg *segment, blockNum int) (*block, error) {
+	key := blockKey{seg: seg, block: blockNum}
+
+	fr.mu.Lock()
+	if b, ok := fr.cache.get(key); ok {
+		fr.mu.Unlock()
+		return b, nil
+	}
+	if blockNum >= len(seg.index.checkpoints) {
+		fr.mu.Unlock()
+		return &block{}, nil
+	}
+	offset := seg.index.checkpoints[blockNum]
+	limit := seg.index.size
+	count := min(seg.index.lines()-blockNum*indexStride, indexStride)
+	complete := (blockNum+1)*indexStride <= seg.index.count
+	fr.mu.Unlock()
+
+	b, err := readBlock(seg.file, offset, limit, count, seg.index.split)
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range b.lines {
+		b.lines[i] = fr.decode(line)
+	}
+
+	// Only complete blocks are cached, the last line may still grow. The
+	// last block read is kept too, in case the file is truncated.
+	fr.mu.Lock()
+	if complete && len(b.lines) == indexStride {
+		fr.cache.add(key, b)
+	} else {
+		seg.last, seg.lastNum = b, blockNum
+	}
+	fr.mu.Unlock()
+
+	return b, nil
 }
 
-// LineCount returns the total number of lines
+// isLoaded reports whether Load has been called
+func (fr *FileReader) isLoaded() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.loaded
+}
+
+// LineCount returns the number of lines available so far
 func (fr *FileReader) LineCount() int {
-	if !fr.loaded {
+	if !fr.isLoaded() {
 		fr.Load() // Ignore error, will return 0
 	}
-	return len(fr.lines)
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.lineCount()
+}
+
+// lineCount returns the number of lines available so far. fr.mu must be
+// held.
+func (fr *FileReader) lineCount() int {
+	if fr.segments == nil {
+		return len(fr.lines)
+	}
+
+	total := 0
+	for _, seg := range fr.segments {
+		total += seg.lines()
+	}
+	return total
+}
+
+// settledLines returns the number of lines that can no longer change:
+// all but a last line without delimiter at the end of a file, which may
+// still grow
+func (fr *FileReader) settledLines() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	count := fr.lineCount()
+	if fr.stream != nil {
+		return count // Only the end of the stream has such a line
+	}
+	if last := fr.segments[len(fr.segments)-1]; last.index.lines() > last.index.count {
+		return count - 1
+	}
+	return count
+}
+
+// CRLF reports whether lines were found ending in CRLF
+func (fr *FileReader) CRLF() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.crlf
+}
+
+// Separator returns what ends each line in the input: the delimiter or
+// nothing for fixed-length records, and CRLF or LF for lines
+func (fr *FileReader) Separator() string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.split.newline() && fr.crlf {
+		return "\r\n"
+	}
+	return fr.split.separator()
+}
+
+// Terminated reports whether the last line read ends in a delimiter.
+// Fixed-length records have none, so they always do.
+func (fr *FileReader) Terminated() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.segments == nil {
+		return !fr.open
+	}
+	last := fr.segments[len(fr.segments)-1]
+	return last.index.lines() == last.index.count
+}
+
+// Gutter marks the lines of multiplexed Docker streams written to stderr
+func (fr *FileReader) Gutter(index int) string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if index < 0 || index/64 >= len(fr.stderr) || fr.stderr[index/64]&(1<<(index%64)) == 0 {
+		return ""
+	}
+	return stderrMarker
+}
+
+// HasGutter reports whether the content is a multiplexed Docker stream
+func (fr *FileReader) HasGutter() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.muxed
+}
+
+// CacheStats returns the statistics of the line cache shared by all files
+func (fr *FileReader) CacheStats() CacheStats {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.cache.stats()
+}
+
+// Binary reports whether the content looks like binary data
+func (fr *FileReader) Binary() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	return fr.binary
+}
+
+// HexDump returns a hex dump of the file. Streams are not kept as raw
+// bytes, so only regular files can be dumped.
+func (fr *FileReader) HexDump() (LineSource, error) {
+	if fr.stream != nil {
+		return nil, errors.New("hex view is only available for regular files")
+	}
+
+	file := fr.current().file
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewHexReader(file, info.Size(), fr.filename), nil
 }
 
 // Filename returns the name of the file being read
@@ -110,10 +846,33 @@ func (fr *FileReader) Filename() string {
 	return fr.filename
 }
 
-// Close closes the underlying file
+// Close stops background indexing and closes the underlying files,
+// removing the temporary file of a spilled stream
 func (fr *FileReader) Close() error {
-	if fr.file != nil {
-		return (*fr.file).Close()
+	select {
+	case <-fr.done:
+		return nil
+	default:
+		close(fr.done)
 	}
-	return nil
+
+	var firstErr error
+	if fr.stream != nil {
+		firstErr = fr.stream.Close()
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	// The shared cache has no use for the blocks of a closed file
+	for _, seg := range fr.segments {
+		fr.cache.take(seg)
+		if seg.file == nil {
+			continue
+		}
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
@@ -0,0 +1,264 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// hexRowSize is the number of bytes shown per hex dump row
+	hexRowSize = 16
+
+	// hexColumn is where the hex bytes start in a row, after the offset
+	hexColumn = 10
+
+	// maxHexMatches limits the results of a hex search, a pattern like
+	// "00" can match most of a binary file
+	maxHexMatches = 100000
+)
+
+// isBinary reports whether content starting with sample looks like binary
+// data rather than text: it contains NUL bytes or many control characters
+func isBinary(sample []byte) bool {
+	if len(sample) == 0 {
+		return false
+	}
+
+	control := 0
+	for _, b := range sample {
+		switch {
+		case b == 0:
+			return true
+		case b == '\t' || b == '\n' || b == '\r' || b == '\f' || b == 0x1b:
+		case b < 0x20 || b == 0x7f:
+			control++
+		}
+	}
+	return control > len(sample)/10
+}
+
+// HexReader shows the raw bytes of a file as a hex dump, with an offset
+// column, 16 bytes per row and their printable characters
+type HexReader struct {
+	file     io.ReaderAt
+	size     int64
+	filename string
+	updates  chan struct{}
+}
+
+// NewHexReader creates a hex dump of size bytes of a file
+func NewHexReader(file io.ReaderAt, size int64, filename string) *HexReader {
+	return &HexReader{
+		file:     file,
+		size:     size,
+		filename: filename,
+		updates:  make(chan struct{}),
+	}
+}
+
+// Load does nothing, rows are formatted on demand
+func (hr *HexReader) Load() error {
+	return nil
+}
+
+// LineCount returns the number of rows
+func (hr *HexReader) LineCount() int {
+	return int((hr.size + hexRowSize - 1) / hexRowSize)
+}
+
+// GetLine returns the row at the specified index (0-based)
+func (hr *HexReader) GetLine(index int) (string, error) {
+	lines, err := hr.GetLines(index, index+1)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", errors.New("line index out of bounds")
+	}
+
+	return lines[0], nil
+}
+
+// GetLines returns a range of rows [start, end)
+func (hr *HexReader) GetLines(start, end int) ([]string, error) {
+	if start < 0 {
+		start = 0
+	}
+	if end > hr.LineCount() {
+		end = hr.LineCount()
+	}
+	if start >= end {
+		return []string{}, nil
+	}
+
+	offset := int64(start) * hexRowSize
+	buf := make([]byte, min(int64(end-start)*hexRowSize, hr.size-offset))
+	n, err := hr.file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	lines := make([]string, 0, end-start)
+	for len(buf) > 0 {
+		row := buf[:min(hexRowSize, len(buf))]
+		lines = append(lines, formatHexRow(offset, row))
+		offset += int64(len(row))
+		buf = buf[len(row):]
+	}
+
+	return lines, nil
+}
+
+// formatHexRow formats one row of the dump:
+// 00000000  7f 45 4c 46 02 01 01 00  00 00 00 00 00 00 00 00  |.ELF............|
+func formatHexRow(offset int64, row []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%08x  ", offset)
+
+	for i := 0; i < hexRowSize; i++ {
+		if i < len(row) {
+			fmt.Fprintf(&sb, "%02x ", row[i])
+		} else {
+			sb.WriteString("   ")
+		}
+		if i == hexRowSize/2-1 {
+			sb.WriteByte(' ')
+		}
+	}
+
+	sb.WriteString(" |")
+	for _, b := range row {
+		if b >= 0x20 && b < 0x7f {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteByte('.')
+		}
+	}
+	sb.WriteByte('|')
+
+	return sb.String()
+}
+
+// Search finds a byte pattern in the file. Terms written as hex bytes,
+// like "7f 45 4c 46" or "0x7f454c46", are searched as bytes, anything
+// else, such as "cafe" or "2024", as text. Each match is reported in the
+// hex column of its row.
+func (hr *HexReader) Search(term string) ([]Match, error) {
+	pattern, ok := parseHexPattern(term)
+	if !ok {
+		pattern = []byte(term)
+	}
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+
+	var matches []Match
+	chunk := make([]byte, 1024*1024+len(pattern)-1)
+	for offset := int64(0); offset < hr.size && len(matches) < maxHexMatches; {
+		n, err := hr.file.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return matches, err
+		}
+
+		data := chunk[:n]
+		for pos := 0; len(matches) < maxHexMatches; pos++ {
+			i := bytes.Index(data[pos:], pattern)
+			if i < 0 {
+				break
+			}
+			pos += i
+			matches = append(matches, hexMatch(offset+int64(pos), len(pattern)))
+		}
+
+		// Overlap chunks so matches across the boundary are found once
+		if err == io.EOF || n < len(chunk) {
+			break
+		}
+		offset += int64(n - len(pattern) + 1)
+	}
+
+	return matches, nil
+}
+
+// hexMatch locates a match of n bytes at offset in the hex column. A match
+// running past the end of its row is only highlighted on that row.
+func hexMatch(offset int64, n int) Match {
+	col := int(offset % hexRowSize)
+	n = min(n, hexRowSize-col)
+
+	// Byte i is at hexColumn + 3*i, plus one after the middle gap
+	start := hexColumn + 3*col
+	end := hexColumn + 3*(col+n) - 1
+	if col >= hexRowSize/2 {
+		start++
+	}
+	if col+n > hexRowSize/2 {
+		end++
+	}
+
+	return Match{
+		Line:   int(offset / hexRowSize),
+		Index:  start,
+		Length: end - start,
+	}
+}
+
+// parseHexPattern parses a search term written as hex bytes: with a 0x
+// prefix, or as two or more byte pairs separated by spaces. Other terms
+// are text, even when made of hex digits.
+func parseHexPattern(term string) ([]byte, bool) {
+	var s string
+	if rest, ok := strings.CutPrefix(strings.ToLower(term), "0x"); ok {
+		s = strings.ReplaceAll(rest, " ", "")
+	} else {
+		pairs := strings.Fields(term)
+		if len(pairs) < 2 {
+			return nil, false
+		}
+		for _, pair := range pairs {
+			if len(pair) != 2 {
+				return nil, false
+			}
+		}
+		s = strings.Join(pairs, "")
+	}
+	if s == "" || len(s)%2 != 0 {
+		return nil, false
+	}
+
+	pattern, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	return pattern, true
+}
+
+// Filename returns the name of the dumped file
+func (hr *HexReader) Filename() string {
+	return hr.filename + " (hex)"
+}
+
+// Updates returns a channel that never receives, the dump does not change
+func (hr *HexReader) Updates() <-chan struct{} {
+	return hr.updates
+}
+
+// Loading returns false, rows are available immediately
+func (hr *HexReader) Loading() bool {
+	return false
+}
+
+// Err returns nil, read errors are returned by GetLines
+func (hr *HexReader) Err() error {
+	return nil
+}
+
+// Close does nothing, the file belongs to the reader that created the dump
+func (hr *HexReader) Close() error {
+	return nil
+}
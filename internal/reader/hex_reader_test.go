@@ -0,0 +1,34 @@
+package reader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseHexPattern(t *testing.T) {
+	tests := []struct {
+		term string
+		want []byte // nil for text
+	}{
+		{"7f 45 4c 46", []byte{0x7f, 0x45, 0x4c, 0x46}},
+		{"0x7f454c46", []byte{0x7f, 0x45, 0x4c, 0x46}},
+		{"0X7F 45", []byte{0x7f, 0x45}},
+		{"0x00", []byte{0x00}},
+		{"CA FE", []byte{0xca, 0xfe}},
+		{"cafe", nil},
+		{"2024", nil},
+		{"added", nil},
+		{"7f", nil},
+		{"0x", nil},
+		{"0x7f4", nil},
+		{"7f 454c", nil},
+		{"ab zz", nil},
+		{"hello world", nil},
+	}
+	for _, tt := range tests {
+		got, ok := parseHexPattern(tt.term)
+		if ok != (tt.want != nil) || !bytes.Equal(got, tt.want) {
+			t.Errorf("parseHexPattern(%q) = %x, %v; want %x", tt.term, got, ok, tt.want)
+		}
+	}
+}
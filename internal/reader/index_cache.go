@@ -0,0 +1,225 @@
+package reader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// indexCacheVersion changes whenever the saved index format does
+	indexCacheVersion = 2
+
+	// minCachedIndexSize is the file size from which line indexes are
+	// saved, smaller files are indexed quickly enough
+	minCachedIndexSize = 16 * 1024 * 1024
+
+	// fingerprintSize is the number of bytes hashed at the start of the
+	// file and before the end of the indexed part
+	fingerprintSize = 64 * 1024
+
+	// maxIndexAge is how long a saved index is kept without being used
+	maxIndexAge = 30 * 24 * time.Hour
+)
+
+// pruned makes sure old indexes are looked for once per run
+var pruned sync.Once
+
+// savedIndex is a line index stored in the cache directory
+type savedIndex struct {
+	Version      int
+	Path         string
+	Size         int64
+	ModTime      int64
+	Head         [sha256.Size]byte // Hash of the start of the file
+	Tail         [sha256.Size]byte // Hash of the bytes before Size
+	Delimiter    []byte            // How the file was split into lines
+	RecordLength int
+	Count        int
+	End          int64
+	Checkpoints  []int64
+}
+
+// indexCachePath returns where the index of a file is saved, along with
+// the absolute path of the file
+func indexCachePath(path string) (string, string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "gless", hex.EncodeToString(sum[:16])+".idx"), abs, nil
+}
+
+// fingerprint hashes the fingerprintSize bytes of f before offset end
+func fingerprint(f *os.File, end int64) ([sha256.Size]byte, error) {
+	start := max(end-fingerprintSize, 0)
+	buf := make([]byte, end-start)
+	if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(buf), nil
+}
+
+// loadIndex returns the saved index of a file if it is still valid for
+// splitting it into lines the same way. An index of a file that has only
+// been appended to since is valid for the part it covers, indexing
+// continues after its last line. An index that is not valid is removed,
+// it would be replaced once the file is indexed again anyway.
+func loadIndex(f *os.File, path string, split *splitter) *lineIndex {
+	info, err := f.Stat()
+	if err != nil || info.Size() < minCachedIndexSize {
+		return nil
+	}
+
+	cachePath, abs, err := indexCachePath(path)
+	if err != nil {
+		return nil
+	}
+	cacheFile, err := os.Open(cachePath)
+	if err != nil {
+		return nil
+	}
+	idx := readIndex(cacheFile, f, info, abs, split)
+	cacheFile.Close()
+
+	if idx == nil {
+		os.Remove(cachePath)
+		return nil
+	}
+
+	// Indexes in use are not pruned
+	now := time.Now()
+	os.Chtimes(cachePath, now, now)
+	return idx
+}
+
+// readIndex decodes a saved index, returning nil if it does not match
+// the file at abs as it is now
+func readIndex(r io.Reader, f *os.File, info os.FileInfo, abs string, split *splitter) *lineIndex {
+	var saved savedIndex
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return nil
+	}
+	if saved.Version != indexCacheVersion || saved.Path != abs || info.Size() < saved.Size {
+		return nil
+	}
+	if !bytes.Equal(saved.Delimiter, split.delim) || saved.RecordLength != split.length {
+		return nil
+	}
+	if info.Size() == saved.Size && info.ModTime().UnixNano() != saved.ModTime {
+		return nil
+	}
+
+	// The file must start and continue up to the indexed size as it did
+	head, err := fingerprint(f, min(fingerprintSize, saved.Size))
+	if err != nil || head != saved.Head {
+		return nil
+	}
+	tail, err := fingerprint(f, saved.Size)
+	if err != nil || tail != saved.Tail {
+		return nil
+	}
+
+	// A delimiter may have been cut at the end of the saved part
+	return &lineIndex{
+		split:       split,
+		checkpoints: saved.Checkpoints,
+		count:       saved.Count,
+		end:         saved.End,
+		size:        saved.End,
+	}
+}
+
+// saveIndex stores the index of a file in the cache directory. Failures
+// are ignored, the index can always be built again.
+func saveIndex(f *os.File, path string, idx *lineIndex) {
+	info, err := f.Stat()
+	if err != nil || idx.size < minCachedIndexSize {
+		return
+	}
+	cachePath, abs, err := indexCachePath(path)
+	if err != nil {
+		return
+	}
+
+	saved := savedIndex{
+		Version:      indexCacheVersion,
+		Path:         abs,
+		Size:         idx.size,
+		Delimiter:    idx.split.delim,
+		RecordLength: idx.split.length,
+		Count:        idx.count,
+		End:          idx.end,
+		Checkpoints:  idx.checkpoints,
+	}
+	if info.Size() == idx.size {
+		saved.ModTime = info.ModTime().UnixNano()
+	}
+	if saved.Head, err = fingerprint(f, min(fingerprintSize, idx.size)); err != nil {
+		return
+	}
+	if saved.Tail, err = fingerprint(f, idx.size); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(saved); err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return
+	}
+
+	// Write to a temporary file first, so readers never see half an index
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), "index-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	pruned.Do(func() { pruneIndexes(filepath.Dir(cachePath)) })
+}
+
+// pruneIndexes removes the indexes in dir that were not used for
+// maxIndexAge, such as those of deleted files, and temporary files left
+// behind by runs that were killed while saving
+func pruneIndexes(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if temp, _ := filepath.Match("index-*.tmp", name); !temp && filepath.Ext(name) != ".idx" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < maxIndexAge {
+			continue
+		}
+		os.Remove(filepath.Join(dir, name))
+	}
+}
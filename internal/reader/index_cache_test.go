@@ -0,0 +1,86 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useCacheDir points the user cache directory to a temporary one
+func useCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+}
+
+func TestLoadIndexRemovesInvalid(t *testing.T) {
+	useCacheDir(t)
+
+	path := filepath.Join(t.TempDir(), "big.log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(minCachedIndexSize); err != nil {
+		t.Fatal(err)
+	}
+
+	saveIndex(f, path, &lineIndex{
+		split:       newlines,
+		checkpoints: []int64{0},
+		end:         minCachedIndexSize,
+		size:        minCachedIndexSize,
+	})
+	cachePath, _, err := indexCachePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadIndex(f, path, newlines) == nil {
+		t.Fatal("saved index not loaded")
+	}
+
+	// The file no longer starts as it did
+	if _, err := f.WriteAt([]byte("changed"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if loadIndex(f, path, newlines) != nil {
+		t.Fatal("index of a changed file loaded")
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("invalid index kept: %v", err)
+	}
+}
+
+func TestPruneIndexes(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-maxIndexAge - time.Hour)
+	files := map[string]bool{ // Whether the file is kept
+		"old.idx":         false,
+		"recent.idx":      true,
+		"index-1.tmp":     false,
+		"unrelated.cache": true,
+	}
+	for name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if name != "recent.idx" {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	pruneIndexes(dir)
+
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s exists: %v, want %v", name, exists, kept)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package reader
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// indexStride is the number of lines between two recorded offsets.
+// A line is located by seeking to the nearest checkpoint and scanning
+// forward, so the index costs one int64 per indexStride lines.
+const indexStride = 256
+
+// longLineSize is the length above which a line is not cached but read
+// from the file each time it is requested
+const longLineSize = 1024 * 1024
+
+// lineIndex is a sparse index of line start offsets in a seekable file
+type lineIndex struct {
+	split       *splitter // How the file is split into lines
+	checkpoints []int64   // Offset of line k*indexStride
+	count       int       // Number of terminated lines
+	end         int64     // Offset just past the last terminated line
+	size        int64     // Number of bytes scanned so far
+	eof         bool      // Scanning has reached the end of the file
+	rest        []byte    // Bytes after end that may begin a delimiter or record
+}
+
+// newLineIndex creates an empty index
+func newLineIndex(split *splitter) *lineIndex {
+	return &lineIndex{
+		split:       split,
+		checkpoints: []int64{0},
+	}
+}
+
+// add records the line ends found in the next chunk of the file
+func (idx *lineIndex) add(chunk []byte) {
+	// A delimiter may begin in the previous chunk, and a fixed-length
+	// record may span several
+	data := chunk
+	if len(idx.rest) > 0 {
+		data = append(idx.rest, chunk...)
+	}
+	base := idx.size - int64(len(data)-len(chunk))
+
+	pos := 0
+	for {
+		next := idx.split.next(data, pos)
+		if next < 0 {
+			break
+		}
+		pos = next
+		idx.count++
+		idx.end = base + int64(pos)
+		if idx.count%indexStride == 0 {
+			idx.checkpoints = append(idx.checkpoints, idx.end)
+		}
+	}
+	idx.size += int64(len(chunk))
+
+	// Only what could still be part of a delimiter or record is kept
+	keep := len(idx.split.delim) - 1
+	if idx.split.length > 0 {
+		keep = idx.split.length - 1
+	}
+	idx.rest = append(idx.rest[:0], data[max(pos, len(data)-keep):]...)
+}
+
+// lines returns the number of lines known to the index. A trailing line
+// without a newline is only counted once scanning has reached EOF.
+func (idx *lineIndex) lines() int {
+	if idx.eof && idx.size > idx.end {
+		return idx.count + 1
+	}
+	return idx.count
+}
+
+// block is a cached run of up to indexStride lines
+type block struct {
+	lines []string
+	long  map[int]lineRef // Lines too long to keep, by position in the block
+}
+
+// lineRef locates a line that is read from the file each time it is needed
+type lineRef struct {
+	offset int64
+	length int
+}
+
+// readBlock reads up to n lines starting at the given checkpoint offset,
+// without reading past limit. Lines longer than longLineSize are not
+// kept in the block, only their location is.
+func readBlock(r io.ReaderAt, offset, limit int64, n int, split *splitter) (*block, error) {
+	br := bufio.NewReaderSize(io.NewSectionReader(r, offset, limit-offset), 64*1024)
+
+	b := &block{lines: make([]string, 0, n)}
+	pos := offset
+	for len(b.lines) < n {
+		line, length, err := split.readRecord(br, longLineSize)
+		if length > 0 {
+			if line == nil {
+				if b.long == nil {
+					b.long = make(map[int]lineRef)
+				}
+				b.long[len(b.lines)] = lineRef{offset: pos, length: length}
+				b.lines = append(b.lines, "")
+			} else {
+				b.lines = append(b.lines, split.trim(string(line)))
+			}
+			pos += int64(length)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// readLongLine reads a line that was too long to keep in its block, or
+// only its first limit bytes
+func readLongLine(r io.ReaderAt, ref lineRef, split *splitter, limit int) (string, error) {
+	buf := make([]byte, min(ref.length, limit))
+	n, err := r.ReadAt(buf, ref.offset)
+	if err != nil && !(err == io.EOF && n == len(buf)) {
+		return "", err
+	}
+	if len(buf) < ref.length {
+		return string(buf), nil
+	}
+	return split.trim(string(buf)), nil
+}
+
+// readLine reads up to and including the next newline, however long the
+// line is. Lines longer than limit are skipped: a nil slice is returned
+// along with the number of bytes consumed.
+func readLine(br *bufio.Reader, limit int) ([]byte, int, error) {
+	return newlines.readRecord(br, limit)
+}
+
+// trimEOL removes a trailing LF or CRLF
+func trimEOL(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
@@ -0,0 +1,346 @@
+package reader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMessageSize is the largest message accepted from the network
+const maxMessageSize = 1024 * 1024
+
+// ListenReader receives log messages on a UDP, TCP or unix socket, like
+// a local syslog endpoint. Each line is prefixed with the address it came
+// from and the time it was received.
+//
+// UDP datagrams are one message each. Stream connections send messages
+// separated by newlines, or octet counted as "<length> <message>" (RFC
+// 6587); a connection starting with a length followed by a syslog
+// message, such as "12 <13>", is taken as the latter.
+type ListenReader struct {
+	mu       sync.Mutex
+	address  string
+	listener net.Listener   // TCP and unix sockets
+	packets  net.PacketConn // UDP
+	conns    map[net.Conn]bool
+	lines    []string
+	loaded   bool
+	closed   bool
+	stopped  bool  // Receiving failed, no more messages can arrive
+	err      error // Why receiving stopped, or the last connection failed
+	updates  chan struct{}
+}
+
+// NewListenReader starts listening on an address given as
+// udp://host:port, tcp://host:port or unix:///path/to/socket
+func NewListenReader(address string) (*ListenReader, error) {
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("invalid listen address %q, expected udp://, tcp:// or unix://", address)
+	}
+
+	lr := &ListenReader{
+		address: address,
+		conns:   make(map[net.Conn]bool),
+		updates: make(chan struct{}, 1),
+	}
+
+	var err error
+	switch network {
+	case "udp":
+		lr.packets, err = net.ListenPacket("udp", addr)
+	case "tcp", "unix":
+		lr.listener, err = net.Listen(network, addr)
+	default:
+		return nil, fmt.Errorf("unsupported listen protocol %q", network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return lr, nil
+}
+
+// Load starts receiving messages in the background
+func (lr *ListenReader) Load() error {
+	lr.mu.Lock()
+	if lr.loaded {
+		lr.mu.Unlock()
+		return nil
+	}
+	lr.loaded = true
+	lr.mu.Unlock()
+
+	if lr.packets != nil {
+		go lr.receivePackets()
+	} else {
+		go lr.accept()
+	}
+	return nil
+}
+
+// receivePackets adds each UDP datagram as a message
+func (lr *ListenReader) receivePackets() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := lr.packets.ReadFrom(buf)
+		if err != nil {
+			lr.stop(err)
+			return
+		}
+		lr.addMessage(addr, string(buf[:n]))
+	}
+}
+
+// accept receives messages from each connection to a stream socket
+func (lr *ListenReader) accept() {
+	for {
+		conn, err := lr.listener.Accept()
+		if err != nil {
+			lr.stop(err)
+			return
+		}
+
+		lr.mu.Lock()
+		if lr.closed {
+			lr.mu.Unlock()
+			conn.Close()
+			return
+		}
+		lr.conns[conn] = true
+		lr.mu.Unlock()
+
+		go lr.receive(conn)
+	}
+}
+
+// receive adds the messages sent on a connection until it is closed
+func (lr *ListenReader) receive(conn net.Conn) {
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.conns, conn)
+		lr.mu.Unlock()
+
+		conn.Close()
+	}()
+
+	br := bufio.NewReaderSize(conn, 64*1024)
+
+	// The framing is the same for the whole connection, told by its start
+	counted := octetCounted(br)
+	for {
+		message, err := readMessage(br, counted)
+		if message != "" {
+			lr.addMessage(conn.RemoteAddr(), message)
+		}
+		if err != nil {
+			lr.connectionFailed(conn, err)
+			return
+		}
+	}
+}
+
+// octetCounted reports whether a connection starts with an octet counted
+// message: a length, a space and a syslog message, which starts with "<"
+func octetCounted(br *bufio.Reader) bool {
+	// Bytes are peeked one at a time, so a short message is not waited on
+	for n := 1; n <= 11; n++ {
+		head, err := br.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b := head[n-1]; {
+		case b >= '0' && b <= '9' && n <= 10:
+		case b == ' ' && n > 1:
+			head, err = br.Peek(n + 1)
+			return err == nil && head[n] == '<'
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// readMessage reads one octet counted or newline separated message
+func readMessage(br *bufio.Reader, counted bool) (string, error) {
+	if !counted {
+		line, _, err := readLine(br, maxMessageSize)
+		return trimEOL(string(line)), err
+	}
+
+	// Some senders end octet counted messages with a newline as well
+	var digits []byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if len(digits) == 0 && (b == '\n' || b == '\r') {
+			continue
+		}
+		if b == ' ' && len(digits) > 0 {
+			break
+		}
+		if b < '0' || b > '9' || len(digits) == 10 {
+			return "", errors.New("invalid octet counted message length")
+		}
+		digits = append(digits, b)
+	}
+
+	length, _ := strconv.Atoi(string(digits))
+	if length > maxMessageSize {
+		return "", fmt.Errorf("message of %d bytes is too large", length)
+	}
+	message := make([]byte, length)
+	n, err := io.ReadFull(br, message)
+	return string(message[:n]), err
+}
+
+// addMessage adds the lines of a message, each prefixed with the sender
+// and the receive time
+func (lr *ListenReader) addMessage(addr net.Addr, message string) {
+	sender := "local"
+	if addr != nil && addr.String() != "" && addr.String() != "@" {
+		sender = addr.String()
+	}
+	prefix := fmt.Sprintf("\x1b[2m%s %s\x1b[0m ", time.Now().Format("15:04:05.000"), sender)
+
+	message = strings.TrimRight(message, "\r\n")
+
+	lr.mu.Lock()
+	for _, line := range strings.Split(message, "\n") {
+		lr.lines = append(lr.lines, prefix+strings.TrimSuffix(line, "\r"))
+	}
+	lr.mu.Unlock()
+
+	lr.notify()
+}
+
+// stop records why receiving stopped, unless the reader was closed
+func (lr *ListenReader) stop(err error) {
+	lr.mu.Lock()
+	if !lr.closed {
+		lr.stopped = true
+		lr.err = err
+	}
+	lr.mu.Unlock()
+
+	lr.notify()
+}
+
+// connectionFailed records why a connection ended, unless the sender
+// closed it or the reader was closed. Other connections go on.
+func (lr *ListenReader) connectionFailed(conn net.Conn, err error) {
+	if err == io.EOF {
+		return
+	}
+
+	lr.mu.Lock()
+	if !lr.closed {
+		lr.err = fmt.Errorf("%s: %w", conn.RemoteAddr(), err)
+	}
+	lr.mu.Unlock()
+
+	lr.notify()
+}
+
+// notify signals that messages arrived without blocking
+func (lr *ListenReader) notify() {
+	select {
+	case lr.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Updates returns a channel that receives a value whenever messages arrive
+func (lr *ListenReader) Updates() <-chan struct{} {
+	return lr.updates
+}
+
+// Loading reports whether more messages can arrive
+func (lr *ListenReader) Loading() bool {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return !lr.closed && !lr.stopped
+}
+
+// Err returns the error that stopped receiving or ended the last failed
+// connection, if any
+func (lr *ListenReader) Err() error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return lr.err
+}
+
+// LineCount returns the number of lines received so far
+func (lr *ListenReader) LineCount() int {
+	lr.Load()
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return len(lr.lines)
+}
+
+// GetLine returns the line at the specified index (0-based)
+func (lr *ListenReader) GetLine(index int) (string, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if index < 0 || index >= len(lr.lines) {
+		return "", errors.New("line index out of bounds")
+	}
+	return lr.lines[index], nil
+}
+
+// GetLines returns a range of lines [start, end)
+func (lr *ListenReader) GetLines(start, end int) ([]string, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if start < 0 {
+		start = 0
+	}
+	if end > len(lr.lines) {
+		end = len(lr.lines)
+	}
+	if start >= end {
+		return []string{}, nil
+	}
+
+	result := make([]string, end-start)
+	copy(result, lr.lines[start:end])
+	return result, nil
+}
+
+// Filename returns the address listened on
+func (lr *ListenReader) Filename() string {
+	return lr.address
+}
+
+// Close stops listening and closes the open connections
+func (lr *ListenReader) Close() error {
+	lr.mu.Lock()
+	if lr.closed {
+		lr.mu.Unlock()
+		return nil
+	}
+	lr.closed = true
+	for conn := range lr.conns {
+		conn.Close()
+	}
+	lr.mu.Unlock()
+
+	if lr.packets != nil {
+		return lr.packets.Close()
+	}
+	return lr.listener.Close()
+}
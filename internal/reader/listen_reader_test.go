@@ -0,0 +1,170 @@
+package reader
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOctetCounted(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"12 <13>hello world", true},
+		{"\n", false},
+		{"<13>hello\n", false},
+		{"2024-01-02 03:04:05 started\n", false},
+		{"404 not found\n", false},
+		{"5 hello", false},
+		{"1234567890 <13>x", true},
+		{"12345678901 <13>x", false},
+		{"12 ", false},
+		{" 12 <13>", false},
+	}
+	for _, tt := range tests {
+		br := bufio.NewReader(strings.NewReader(tt.input))
+		if got := octetCounted(br); got != tt.want {
+			t.Errorf("octetCounted(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+
+		// Nothing is consumed
+		if rest, _ := io.ReadAll(br); string(rest) != tt.input {
+			t.Errorf("octetCounted(%q) consumed input, %q left", tt.input, rest)
+		}
+	}
+}
+
+func TestListenFraming(t *testing.T) {
+	lr, err := NewListenReader("tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+	lr.Load()
+
+	// Each connection is framed on its own, one after the other so the
+	// lines arrive in order
+	inputs := []struct {
+		data  string
+		lines int
+	}{
+		{"2024-01-02 03:04:05 started\n404 not found\n", 2},
+		{"10 <13>hello\n15 <13>second\nline", 3},
+		{"12 <13>bad\n", 1},
+	}
+	for _, input := range inputs {
+		count := lr.LineCount()
+		conn, err := net.Dial("tcp", lr.listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte(input.data))
+		conn.Close()
+
+		waitFor(t, "the messages", func() bool { return lr.LineCount() == count+input.lines })
+	}
+
+	// The last message is shorter than its length
+	waitFor(t, "the framing error", func() bool { return lr.Err() != nil })
+
+	lines, _ := lr.GetLines(0, lr.LineCount())
+	var messages []string
+	for _, line := range lines {
+		_, message, _ := strings.Cut(line, "\x1b[0m ")
+		messages = append(messages, message)
+	}
+	want := []string{"2024-01-02 03:04:05 started", "404 not found", "<13>hello", "<13>second", "line", "<13>bad"}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+	if !lr.Loading() {
+		t.Error("a failed connection stopped receiving")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		counted bool
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "newline separated",
+			input: "<13>first\n<13>second\r\n",
+			want:  []string{"<13>first", "<13>second"},
+		},
+		{
+			name:  "newline separated starting with a number",
+			input: "404 not found\n500 internal error\n",
+			want:  []string{"404 not found", "500 internal error"},
+		},
+		{
+			name:  "last line without newline",
+			input: "one\ntwo",
+			want:  []string{"one", "two"},
+		},
+		{
+			name:    "octet counted",
+			input:   "5 hello11 hello\nworld",
+			counted: true,
+			want:    []string{"hello", "hello\nworld"},
+		},
+		{
+			name:    "octet counted with newlines between",
+			input:   "3 one\n3 two\r\n",
+			counted: true,
+			want:    []string{"one", "two"},
+		},
+		{
+			name:    "octet counted message cut short",
+			input:   "10 short",
+			counted: true,
+			want:    []string{"short"},
+			wantErr: true,
+		},
+		{
+			name:    "octet counted without a length",
+			input:   "3 one<13>two\n",
+			counted: true,
+			want:    []string{"one"},
+			wantErr: true,
+		},
+		{
+			name:    "octet counted message too large",
+			input:   "99999999 x",
+			counted: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReader(strings.NewReader(tt.input))
+
+			var got []string
+			var err error
+			for {
+				var message string
+				message, err = readMessage(br, tt.counted)
+				if message != "" {
+					got = append(got, message)
+				}
+				if err != nil {
+					break
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+			if gotErr := err != io.EOF; gotErr != tt.wantErr {
+				t.Errorf("error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
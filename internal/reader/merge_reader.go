@@ -0,0 +1,339 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMergeSources is the number of files that can be merged, limited by
+// the one byte used to record the source of each merged line
+const maxMergeSources = 256
+
+// labelColors are the ANSI colors used for source labels
+var labelColors = []string{"36", "33", "35", "32", "34", "31", "96", "93", "95", "92", "94", "91"}
+
+// MergeReader interleaves the lines of several sources into one timeline
+// ordered by the timestamp at the start of each line. Lines without a
+// timestamp, such as stack traces, stay with the line before them.
+// Every line is prefixed with a colored label naming its source.
+type MergeReader struct {
+	mu          sync.Mutex
+	sources     []LineSource
+	labels      []string
+	order       []uint8 // Source of each merged line
+	checkpoints [][]int // Source positions at every indexStride merged lines
+	positions   []int   // Source positions after the last merged line
+	loaded      bool
+	merged      bool
+	err         error
+	updates     chan struct{}
+	done        chan struct{}
+}
+
+// mergeHead tracks the next lines of one source waiting to be merged
+type mergeHead struct {
+	source LineSource
+	next   int         // Position of the next line to merge
+	stamps []time.Time // Timestamps of the buffered lines from next on
+	last   time.Time   // Timestamp of the last parsed line
+	done   bool
+}
+
+// NewMergeReader creates a reader merging the given sources
+func NewMergeReader(sources []LineSource) (*MergeReader, error) {
+	if len(sources) > maxMergeSources {
+		return nil, fmt.Errorf("cannot merge more than %d files", maxMergeSources)
+	}
+
+	names := make([]string, len(sources))
+	width := 0
+	for i, src := range sources {
+		names[i] = filepath.Base(src.Filename())
+		width = max(width, len(names[i]))
+	}
+
+	labels := make([]string, len(sources))
+	for i, name := range names {
+		color := labelColors[i%len(labelColors)]
+		labels[i] = fmt.Sprintf("\x1b[1;%sm%-*s\x1b[0m ", color, width, name)
+	}
+
+	return &MergeReader{
+		sources:   sources,
+		labels:    labels,
+		positions: make([]int, len(sources)),
+		updates:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Load starts loading the sources and merging them in the background
+func (mr *MergeReader) Load() error {
+	mr.mu.Lock()
+	if mr.loaded {
+		mr.mu.Unlock()
+		return nil
+	}
+	mr.loaded = true
+	mr.mu.Unlock()
+
+	for _, src := range mr.sources {
+		if err := src.Load(); err != nil {
+			return err
+		}
+	}
+
+	go mr.merge()
+	return nil
+}
+
+// merge repeatedly takes the earliest next line of all sources. A source
+// that is still loading must deliver its next line before anything can
+// be merged, since that line may be the earliest.
+func (mr *MergeReader) merge() {
+	heads := make([]*mergeHead, len(mr.sources))
+	for i, src := range mr.sources {
+		heads[i] = &mergeHead{source: src}
+	}
+
+	lastNotify := time.Now()
+	for {
+		select {
+		case <-mr.done:
+			return
+		default:
+		}
+
+		best := -1
+		var waiting *mergeHead
+		for i, h := range heads {
+			if h.done {
+				continue
+			}
+
+			ready, err := h.fill()
+			if err != nil {
+				mr.mu.Lock()
+				mr.err = err
+				mr.mu.Unlock()
+
+				mr.notify()
+				return
+			}
+			if !ready {
+				waiting = h
+				break
+			}
+			if h.done {
+				continue
+			}
+
+			if best < 0 || h.stamps[0].Before(heads[best].stamps[0]) {
+				best = i
+			}
+		}
+
+		if waiting != nil {
+			mr.notify()
+			select {
+			case <-mr.done:
+				return
+			case <-waiting.source.Updates():
+			case <-time.After(followInterval):
+			}
+			continue
+		}
+
+		if best < 0 {
+			mr.mu.Lock()
+			mr.merged = true
+			mr.mu.Unlock()
+
+			mr.notify()
+			return
+		}
+
+		mr.mu.Lock()
+		if len(mr.order)%indexStride == 0 {
+			mr.checkpoints = append(mr.checkpoints, slices.Clone(mr.positions))
+		}
+		mr.order = append(mr.order, uint8(best))
+		mr.positions[best]++
+		mr.mu.Unlock()
+
+		heads[best].next++
+		heads[best].stamps = heads[best].stamps[1:]
+
+		if time.Since(lastNotify) >= notifyInterval {
+			mr.notify()
+			lastNotify = time.Now()
+		}
+	}
+}
+
+// fill buffers the timestamps of the source's next lines. It returns
+// false if the source has no next line yet but is still loading.
+func (h *mergeHead) fill() (bool, error) {
+	if len(h.stamps) > 0 {
+		return true, nil
+	}
+
+	count := h.source.LineCount()
+	if h.next >= count {
+		if h.source.Loading() {
+			return false, nil
+		}
+		h.done = true
+		return true, h.source.Err()
+	}
+
+	lines, err := h.source.GetLines(h.next, min(h.next+indexStride, count))
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range lines {
+		if t, ok := parseTimestamp(line); ok {
+			h.last = t
+		}
+		h.stamps = append(h.stamps, h.last)
+	}
+	return true, nil
+}
+
+// notify signals that the line count changed without blocking
+func (mr *MergeReader) notify() {
+	select {
+	case mr.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Updates returns a channel that receives a value whenever new lines
+// become available
+func (mr *MergeReader) Updates() <-chan struct{} {
+	return mr.updates
+}
+
+// Loading reports whether the files are still being merged
+func (mr *MergeReader) Loading() bool {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return !mr.merged && mr.err == nil
+}
+
+// Err returns the error that stopped merging, if any
+func (mr *MergeReader) Err() error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.err
+}
+
+// GetLine returns the merged line at the specified index (0-based)
+func (mr *MergeReader) GetLine(index int) (string, error) {
+	lines, err := mr.GetLines(index, index+1)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", errors.New("line index out of bounds")
+	}
+
+	return lines[0], nil
+}
+
+// GetLines returns a range of merged lines [start, end)
+func (mr *MergeReader) GetLines(start, end int) ([]string, error) {
+	mr.mu.Lock()
+	if start < 0 {
+		start = 0
+	}
+	if end > len(mr.order) {
+		end = len(mr.order)
+	}
+	if start >= end {
+		mr.mu.Unlock()
+		return []string{}, nil
+	}
+
+	// Replay the merge order from the nearest checkpoint
+	checkpoint := start / indexStride
+	from := slices.Clone(mr.checkpoints[checkpoint])
+	for i := checkpoint * indexStride; i < start; i++ {
+		from[mr.order[i]]++
+	}
+	picks := slices.Clone(mr.order[start:end])
+	mr.mu.Unlock()
+
+	// Read each source's part of the range at once
+	counts := make([]int, len(mr.sources))
+	for _, src := range picks {
+		counts[src]++
+	}
+	sourceLines := make([][]string, len(mr.sources))
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		lines, err := mr.sources[i].GetLines(from[i], from[i]+count)
+		if err != nil {
+			return nil, err
+		}
+		sourceLines[i] = lines
+	}
+
+	result := make([]string, 0, len(picks))
+	for _, src := range picks {
+		if len(sourceLines[src]) == 0 {
+			break
+		}
+		result = append(result, mr.labels[src]+sourceLines[src][0])
+		sourceLines[src] = sourceLines[src][1:]
+	}
+
+	return result, nil
+}
+
+// LineCount returns the number of lines merged so far
+func (mr *MergeReader) LineCount() int {
+	mr.Load()
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return len(mr.order)
+}
+
+// Filename returns the names of the merged files
+func (mr *MergeReader) Filename() string {
+	names := make([]string, len(mr.sources))
+	for i, src := range mr.sources {
+		names[i] = src.Filename()
+	}
+	return "merged: " + strings.Join(names, ", ")
+}
+
+// Close stops merging and closes the merged files
+func (mr *MergeReader) Close() error {
+	select {
+	case <-mr.done:
+		return nil
+	default:
+		close(mr.done)
+	}
+
+	var firstErr error
+	for _, src := range mr.sources {
+		if err := src.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,158 @@
+package reader
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxMemory is the memory budget for cached lines of all files
+const DefaultMaxMemory = 256 * 1024 * 1024
+
+// stringOverhead approximates the memory used by a string besides its bytes
+const stringOverhead = 16
+
+// CacheStats describes how well the line cache shared by the files works
+type CacheStats struct {
+	Hits   int64 // Blocks found in the cache
+	Misses int64 // Blocks read from the file
+	Used   int64 // Bytes of cached lines
+	Budget int64 // Bytes the cache may use
+}
+
+// cacheEntry is a cached block of lines
+type cacheEntry struct {
+	key   blockKey
+	block *block
+	size  int64
+}
+
+// pageCache keeps blocks of lines within a memory budget, evicting the
+// least recently used block first. Readers with the same budget share one
+// cache, so the budget holds for all of them together.
+type pageCache struct {
+	mu      sync.Mutex
+	budget  int64
+	used    int64
+	entries map[blockKey]*list.Element
+	lru     *list.List // Most recently used first
+	hits    int64
+	misses  int64
+}
+
+// pageCaches holds the shared caches by budget
+var pageCaches = struct {
+	sync.Mutex
+	byBudget map[int64]*pageCache
+}{byBudget: make(map[int64]*pageCache)}
+
+// sharedPageCache returns the cache of all readers using at most budget
+// bytes, creating it on first use
+func sharedPageCache(budget int64) *pageCache {
+	if budget <= 0 {
+		budget = DefaultMaxMemory
+	}
+
+	pageCaches.Lock()
+	defer pageCaches.Unlock()
+
+	c, ok := pageCaches.byBudget[budget]
+	if !ok {
+		c = newPageCache(budget)
+		pageCaches.byBudget[budget] = c
+	}
+	return c
+}
+
+// newPageCache creates a cache using at most budget bytes
+func newPageCache(budget int64) *pageCache {
+	return &pageCache{
+		budget:  budget,
+		entries: make(map[blockKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns a cached block, marking it as recently used
+func (c *pageCache) get(key blockKey) (*block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).block, true
+}
+
+// add caches a block, evicting the least recently used blocks to stay
+// within the budget. Blocks larger than the whole budget are not cached.
+func (c *pageCache) add(key blockKey, b *block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	size := blockSize(b)
+	if size > c.budget {
+		return
+	}
+	for c.used+size > c.budget {
+		c.remove(c.lru.Back())
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, block: b, size: size})
+	c.used += size
+}
+
+// take removes the cached blocks of a segment and returns them by block
+// number
+func (c *pageCache) take(seg *segment) map[int]*block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blocks := make(map[int]*block)
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*cacheEntry); entry.key.seg == seg {
+			blocks[entry.key.block] = entry.block
+			c.remove(elem)
+		}
+		elem = next
+	}
+	return blocks
+}
+
+// remove evicts a cached block. c.mu must be held.
+func (c *pageCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.used -= entry.size
+}
+
+// stats returns the cache statistics
+func (c *pageCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Used:   c.used,
+		Budget: c.budget,
+	}
+}
+
+// blockSize approximates the memory used by a block
+func blockSize(b *block) int64 {
+	size := int64(len(b.long)) * stringOverhead * 2
+	for _, line := range b.lines {
+		size += int64(len(line)) + stringOverhead
+	}
+	return size
+}
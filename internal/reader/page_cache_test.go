@@ -0,0 +1,38 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSharedPageCache(t *testing.T) {
+	const budget = 10 * 1024
+	a, b := sharedPageCache(budget), sharedPageCache(budget)
+	if a != b {
+		t.Fatal("readers with the same budget got different caches")
+	}
+
+	// Blocks of two files together stay within the one budget
+	first, second := &segment{}, &segment{}
+	line := strings.Repeat("x", 1024)
+	for i := range 8 {
+		a.add(blockKey{seg: first, block: i}, &block{lines: []string{line}})
+		b.add(blockKey{seg: second, block: i}, &block{lines: []string{line}})
+	}
+	if stats := a.stats(); stats.Used > budget {
+		t.Errorf("cache uses %d bytes, budget %d", stats.Used, budget)
+	}
+	if _, ok := a.get(blockKey{seg: first, block: 0}); ok {
+		t.Error("the oldest block of the first file was not evicted")
+	}
+	if _, ok := b.get(blockKey{seg: second, block: 7}); !ok {
+		t.Error("the newest block of the second file was evicted")
+	}
+
+	// Closing a file frees its blocks for the others
+	a.take(first)
+	a.take(second)
+	if stats := a.stats(); stats.Used != 0 {
+		t.Errorf("cache uses %d bytes after taking all blocks", stats.Used)
+	}
+}
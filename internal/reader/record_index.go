@@ -0,0 +1,337 @@
+package reader
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxCachedRecordBlocks is the number of decoded record blocks kept in memory
+	maxCachedRecordBlocks = 8
+
+	// recordScanBatch is the number of lines scanned for records at once
+	recordScanBatch = 16 * indexStride
+)
+
+// recordSource is the content a recordIndex finds records in
+type recordSource interface {
+	LineCount() int
+	settledLines() int
+	GetLinePrefixes(start, end, limit int) ([]string, error)
+	Updates() <-chan struct{}
+	Loading() bool
+}
+
+// lineKind tells how a line takes part in records
+type lineKind struct {
+	start bool // Begins a record, unless the line before continues
+	cont  bool // The record continues in the next line
+}
+
+// recordState is how far the lines of a source were scanned for records
+type recordState struct {
+	checkpoints []int // Source line of every indexStride records
+	records     int   // Records found so far, the last may still grow
+	scanned     int   // Source lines scanned so far
+	lines       int   // Lines of the last record so far
+	cont        bool  // The last line continues in the next one
+}
+
+// add scans more lines of the given kinds. Records longer than maxLines
+// lines are cut, unless it is zero.
+func (s *recordState) add(kinds []lineKind, maxLines int) {
+	for _, kind := range kinds {
+		if s.lines == 0 || (kind.start && !s.cont) || s.lines == maxLines {
+			if s.records%indexStride == 0 {
+				s.checkpoints = append(s.checkpoints, s.scanned)
+			}
+			s.records++
+			s.lines = 0
+		}
+		s.lines++
+		s.cont = kind.cont
+		s.scanned++
+	}
+}
+
+// cachedBlock is a decoded block of records, with the number of bytes
+// read of lines too long to be cached
+type cachedBlock[B any] struct {
+	block B
+	limit int
+}
+
+// recordIndex finds where records made of one or more lines of a source
+// start, for readers showing each record as one line. Like lineIndex, it
+// records where every indexStride records start. Blocks of records are
+// decoded when requested and the most recent ones cached.
+type recordIndex[B any] struct {
+	mu sync.Mutex
+	recordState
+	tentative   *recordState // State before the last lines, which may still change
+	maxLines    int          // Most lines in a record, zero for no limit
+	version     int          // Changes whenever more lines are scanned
+	blocks      map[int]cachedBlock[B]
+	order       []int          // Cached block numbers, oldest first
+	last        cachedBlock[B] // Last, incomplete block as of lastVersion
+	lastNum     int
+	lastVersion int
+	loaded      bool
+	updates     chan struct{}
+	done        chan struct{}
+}
+
+// newRecordIndex creates an empty index of records of up to maxLines
+// lines, zero for no limit
+func newRecordIndex[B any](maxLines int) *recordIndex[B] {
+	return &recordIndex[B]{
+		maxLines:    maxLines,
+		blocks:      make(map[int]cachedBlock[B]),
+		lastVersion: -1,
+		updates:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+// begin reports whether scanning is yet to start, the first time only
+func (ri *recordIndex[B]) begin() bool {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	if ri.loaded {
+		return false
+	}
+	ri.loaded = true
+	return true
+}
+
+// scan finds the records in the lines of src as they arrive, until the
+// index is closed. classify tells how each line takes part in records,
+// from no more than limit bytes of it.
+// Lines that may still change, such as the last line of a followed file
+// without a newline yet, are scanned again on every update.
+func (ri *recordIndex[B]) scan(src recordSource, limit int, classify func(line string) lineKind) {
+	lastNotify := time.Now()
+	rescan := false
+	for {
+		settled := src.settledLines()
+		count := src.LineCount()
+
+		ri.mu.Lock()
+		if count < ri.scanned {
+			ri.reset()
+		}
+		state := ri.recordState
+		again := ri.tentative != nil && (rescan || count > ri.scanned)
+		if again {
+			state = *ri.tentative
+		}
+		ri.mu.Unlock()
+		rescan = false
+
+		if state.scanned < count {
+			lines, err := src.GetLinePrefixes(state.scanned, min(count, state.scanned+recordScanBatch), limit)
+			if err == nil && len(lines) > 0 {
+				// Classifying is the slow part, do it unlocked
+				kinds := make([]lineKind, len(lines))
+				for i, line := range lines {
+					kinds[i] = classify(line)
+				}
+
+				// The state is replaced at once, so records scanned again
+				// do not disappear meanwhile
+				ri.mu.Lock()
+				if again {
+					ri.forget(len(state.checkpoints) - 1)
+				}
+				ri.tentative = nil
+				if n := max(settled-state.scanned, 0); n < len(kinds) {
+					state.add(kinds[:n], ri.maxLines)
+					before := state
+					ri.tentative = &before
+					kinds = kinds[n:]
+				}
+				state.add(kinds, ri.maxLines)
+				ri.recordState = state
+				ri.version++
+				ri.mu.Unlock()
+
+				if time.Since(lastNotify) >= notifyInterval {
+					ri.notify()
+					lastNotify = time.Now()
+				}
+				continue
+			}
+		}
+
+		ri.notify()
+		select {
+		case <-ri.done:
+			return
+		case <-src.Updates():
+			rescan = true
+		}
+	}
+}
+
+// forward passes on the updates of src, for content without records
+func (ri *recordIndex[B]) forward(src recordSource) {
+	for {
+		ri.notify()
+		select {
+		case <-ri.done:
+			return
+		case <-src.Updates():
+		}
+	}
+}
+
+// reset forgets the records found, to scan again the lines of a source
+// that got shorter. ri.mu must be held.
+func (ri *recordIndex[B]) reset() {
+	ri.recordState = recordState{}
+	ri.tentative = nil
+	ri.version++
+	ri.blocks = make(map[int]cachedBlock[B])
+	ri.order = nil
+}
+
+// forget drops the cached blocks from blockNum on, whose records may have
+// changed. ri.mu must be held.
+func (ri *recordIndex[B]) forget(blockNum int) {
+	order := ri.order[:0]
+	for _, num := range ri.order {
+		if num < blockNum {
+			order = append(order, num)
+		} else {
+			delete(ri.blocks, num)
+		}
+	}
+	ri.order = order
+}
+
+// clear empties the cache, for records that are decoded differently
+func (ri *recordIndex[B]) clear() {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	ri.blocks = make(map[int]cachedBlock[B])
+	ri.order = nil
+	ri.lastVersion = -1
+}
+
+// state returns how far scanning got
+func (ri *recordIndex[B]) state() recordState {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	return ri.recordState
+}
+
+// settledState returns how far scanning got before the lines that may
+// still change
+func (ri *recordIndex[B]) settledState() recordState {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	if ri.tentative != nil {
+		return *ri.tentative
+	}
+	return ri.recordState
+}
+
+// loading reports whether more records are expected
+func (ri *recordIndex[B]) loading(src recordSource) bool {
+	return src.Loading() || ri.state().scanned < src.LineCount()
+}
+
+// getBlock returns a block of records, decoding it from the lines of src
+// it spans, read up to limit bytes, if it is not cached. first is the
+// index of the first line. Complete blocks are cached, and the last one
+// until more lines are scanned.
+func (ri *recordIndex[B]) getBlock(src recordSource, blockNum, limit int, decode func(lines []string, first int) B) (B, error) {
+	var none B
+
+	ri.mu.Lock()
+	if cached, ok := ri.blocks[blockNum]; ok && cached.limit >= limit {
+		ri.mu.Unlock()
+		return cached.block, nil
+	}
+	if blockNum >= len(ri.checkpoints) {
+		ri.mu.Unlock()
+		return none, nil
+	}
+	from := ri.checkpoints[blockNum]
+	to := ri.scanned
+	complete := blockNum+1 < len(ri.checkpoints)
+	if complete {
+		to = ri.checkpoints[blockNum+1]
+	} else if ri.lastNum == blockNum && ri.lastVersion == ri.version && ri.last.limit >= limit {
+		b := ri.last.block
+		ri.mu.Unlock()
+		return b, nil
+	}
+	version := ri.version
+	ri.mu.Unlock()
+
+	lines, err := src.GetLinePrefixes(from, to, limit)
+	if err != nil {
+		return none, err
+	}
+	b := decode(lines, from)
+	cached := cachedBlock[B]{block: b, limit: limit}
+
+	// Lines scanned meanwhile may have changed the block
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	if len(lines) != to-from {
+		return b, nil
+	}
+	if complete && blockNum+1 < len(ri.checkpoints) && ri.checkpoints[blockNum] == from && ri.checkpoints[blockNum+1] == to {
+		ri.cache(blockNum, cached)
+	} else if !complete && ri.version == version {
+		ri.last, ri.lastNum, ri.lastVersion = cached, blockNum, version
+	}
+	return b, nil
+}
+
+// cache stores a complete block, evicting the oldest one when full. A
+// block decoded from more of its lines replaces the cached one.
+// ri.mu must be held.
+func (ri *recordIndex[B]) cache(blockNum int, b cachedBlock[B]) {
+	if cached, ok := ri.blocks[blockNum]; ok {
+		if b.limit > cached.limit {
+			ri.blocks[blockNum] = b
+		}
+		return
+	}
+	if len(ri.order) >= maxCachedRecordBlocks {
+		delete(ri.blocks, ri.order[0])
+		ri.order = ri.order[1:]
+	}
+	ri.blocks[blockNum] = b
+	ri.order = append(ri.order, blockNum)
+}
+
+// notify signals that records were found without blocking
+func (ri *recordIndex[B]) notify() {
+	select {
+	case ri.updates <- struct{}{}:
+	default:
+	}
+}
+
+// close stops scanning, returning false if it already was
+func (ri *recordIndex[B]) close() bool {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	select {
+	case <-ri.done:
+		return false
+	default:
+		close(ri.done)
+		return true
+	}
+}
@@ -0,0 +1,250 @@
+package reader
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/iqoologic/gless/internal/ansi"
+)
+
+const (
+	// maxTailRecordLines limits how many lines are read back from the end
+	// to find the start of the last records
+	maxTailRecordLines = 64 * 1024
+
+	// maxRecordLines is the most lines a record has, a new one starts
+	// after that even without a match
+	maxRecordLines = 10 * 1024
+)
+
+// recordBlock is a cached run of up to indexStride records
+type recordBlock struct {
+	records []string
+	starts  []int // Line each record starts at
+}
+
+// RecordReader groups the lines of a log into records, each starting at
+// a line matching a regular expression, so a message and its
+// continuation lines, such as a stack trace, are one line to the viewer.
+// Lines before the first match form a record of their own.
+type RecordReader struct {
+	*ContainerLogReader
+
+	start *regexp.Regexp
+	index *recordIndex[recordBlock]
+}
+
+// NewRecordReader creates a reader grouping the lines read by cr into
+// records starting at lines matching start
+func NewRecordReader(cr *ContainerLogReader, start *regexp.Regexp) *RecordReader {
+	return &RecordReader{
+		ContainerLogReader: cr,
+		start:              start,
+		index:              newRecordIndex[recordBlock](maxRecordLines),
+	}
+}
+
+// Load starts reading the file and finding its records in the background
+func (rr *RecordReader) Load() error {
+	if !rr.index.begin() {
+		return nil
+	}
+
+	if err := rr.ContainerLogReader.Load(); err != nil {
+		return err
+	}
+
+	go rr.index.scan(rr.ContainerLogReader, longLineSize, rr.classify)
+	return nil
+}
+
+// startsRecord reports whether a line begins a new record. Only the
+// start of very long lines is matched, which is all that is read of
+// them when scanning.
+func (rr *RecordReader) startsRecord(line string) bool {
+	return rr.start.MatchString(ansi.StripANSI(line[:min(len(line), longLineSize)]))
+}
+
+// classify tells a line starting a record from a continuation line
+func (rr *RecordReader) classify(line string) lineKind {
+	return lineKind{start: rr.startsRecord(line)}
+}
+
+// Updates returns a channel that receives a value whenever new records
+// become available
+func (rr *RecordReader) Updates() <-chan struct{} {
+	return rr.index.updates
+}
+
+// Loading reports whether more records are expected
+func (rr *RecordReader) Loading() bool {
+	return rr.index.loading(rr.ContainerLogReader)
+}
+
+// LineCount returns the number of records found so far
+func (rr *RecordReader) LineCount() int {
+	rr.Load()
+
+	return rr.index.state().records
+}
+
+// MultiLine reports that records can span several rows
+func (rr *RecordReader) MultiLine() bool {
+	return true
+}
+
+// GetLine returns the record at the specified index (0-based)
+func (rr *RecordReader) GetLine(index int) (string, error) {
+	lines, err := rr.GetLines(index, index+1)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", errors.New("line index out of bounds")
+	}
+
+	return lines[0], nil
+}
+
+// GetLines returns a range of records [start, end), the lines of each
+// record joined by newlines
+func (rr *RecordReader) GetLines(start, end int) ([]string, error) {
+	return rr.getLines(start, end, math.MaxInt)
+}
+
+// GetLinePrefixes returns a range of records [start, end), reading no
+// more than limit bytes of lines too long to be cached
+func (rr *RecordReader) GetLinePrefixes(start, end, limit int) ([]string, error) {
+	return rr.getLines(start, end, limit)
+}
+
+// getLines returns a range of records, long lines cut to limit bytes
+func (rr *RecordReader) getLines(start, end, limit int) ([]string, error) {
+	if start < 0 {
+		start = 0
+	}
+	if end > rr.LineCount() {
+		end = rr.LineCount()
+	}
+	if start >= end {
+		return []string{}, nil
+	}
+
+	result := make([]string, 0, end-start)
+	for blockNum := start / indexStride; blockNum*indexStride < end; blockNum++ {
+		b, err := rr.getBlock(blockNum, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		first := blockNum * indexStride
+		from := min(max(start-first, 0), len(b.records))
+		to := min(end-first, len(b.records))
+		result = append(result, b.records[from:to]...)
+		if to < end-first {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// getBlock returns the records of a block, long lines cut to limit
+// bytes. Records are grouped from at least as much of each line as
+// scanning matches.
+func (rr *RecordReader) getBlock(blockNum, limit int) (recordBlock, error) {
+	return rr.index.getBlock(rr.ContainerLogReader, blockNum, max(limit, longLineSize), rr.group)
+}
+
+// group joins lines into records, the first line starting one. first is
+// the index of the first line.
+func (rr *RecordReader) group(lines []string, first int) recordBlock {
+	var b recordBlock
+	var current []string
+	for i, line := range lines {
+		if i > 0 && (len(current) == maxRecordLines || rr.startsRecord(line)) {
+			b.records = append(b.records, strings.Join(current, "\n"))
+			current = current[:0]
+		}
+		if len(current) == 0 {
+			b.starts = append(b.starts, first+i)
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		b.records = append(b.records, strings.Join(current, "\n"))
+	}
+	return b
+}
+
+// Tail returns the last n records, reading back from the end of the
+// file until the start of the first of them is found
+func (rr *RecordReader) Tail(n int) ([]string, error) {
+	for count := n; ; count *= 4 {
+		lines, err := rr.ContainerLogReader.Tail(count)
+		if err != nil {
+			return nil, err
+		}
+
+		// Lines before the first start belong to an earlier record, unless
+		// the start of the file was reached
+		first := 0
+		if len(lines) == count {
+			for first < len(lines) && !rr.startsRecord(lines[first]) {
+				first++
+			}
+		}
+		records := rr.group(lines[first:], 0).records
+
+		if len(records) >= n || len(lines) < count || count >= maxTailRecordLines {
+			return records[max(len(records)-n, 0):], nil
+		}
+	}
+}
+
+// EstimatedLines estimates the number of records from the records per
+// line found so far
+func (rr *RecordReader) EstimatedLines() int {
+	estimate := rr.ContainerLogReader.EstimatedLines()
+
+	state := rr.index.state()
+	if state.scanned == 0 {
+		return state.records
+	}
+	return estimate * state.records / state.scanned
+}
+
+// TogglePrefix shows or hides the time and stream before each message of
+// a container log, which changes the records
+func (rr *RecordReader) TogglePrefix() bool {
+	if !rr.ContainerLogReader.TogglePrefix() {
+		return false
+	}
+
+	rr.index.clear()
+
+	return true
+}
+
+// Gutter shows the marker of the first line of a record
+func (rr *RecordReader) Gutter(index int) string {
+	if index < 0 || index >= rr.LineCount() {
+		return ""
+	}
+
+	b, err := rr.getBlock(index/indexStride, 0)
+	if err != nil || index%indexStride >= len(b.starts) {
+		return ""
+	}
+	return rr.ContainerLogReader.Gutter(b.starts[index%indexStride])
+}
+
+// Close stops scanning and closes the file
+func (rr *RecordReader) Close() error {
+	if !rr.index.close() {
+		return nil
+	}
+	return rr.ContainerLogReader.Close()
+}
@@ -0,0 +1,118 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	rr := NewRecordReader(nil, regexp.MustCompile(`^\d{4}-\d\d-\d\d`))
+
+	lines := []string{
+		"2024-01-02 first",
+		"2024-01-02 error",
+		"Traceback:",
+		"  File \"app.py\"",
+		"\x1b[31m2024-01-02\x1b[0m colored",
+		"  continued",
+	}
+	b := rr.group(lines, 10)
+
+	wantRecords := []string{
+		"2024-01-02 first",
+		"2024-01-02 error\nTraceback:\n  File \"app.py\"",
+		"\x1b[31m2024-01-02\x1b[0m colored\n  continued",
+	}
+	if !reflect.DeepEqual(b.records, wantRecords) {
+		t.Errorf("records = %q, want %q", b.records, wantRecords)
+	}
+	if want := []int{10, 11, 14}; !reflect.DeepEqual(b.starts, want) {
+		t.Errorf("starts = %v, want %v", b.starts, want)
+	}
+}
+
+func TestGroupMaxRecordLines(t *testing.T) {
+	rr := NewRecordReader(nil, regexp.MustCompile(`^NEVER`))
+
+	lines := make([]string, 2*maxRecordLines+1)
+	for i := range lines {
+		lines[i] = strconv.Itoa(i)
+	}
+	b := rr.group(lines, 0)
+
+	if want := []int{0, maxRecordLines, 2 * maxRecordLines}; !reflect.DeepEqual(b.starts, want) {
+		t.Errorf("starts = %v, want %v", b.starts, want)
+	}
+
+	// Scanning must find the same records
+	kinds := make([]lineKind, len(lines))
+	for i, line := range lines {
+		kinds[i] = rr.classify(line)
+	}
+	var state recordState
+	state.add(kinds, maxRecordLines)
+	if state.records != len(b.records) {
+		t.Errorf("scanning found %d records, group %d", state.records, len(b.records))
+	}
+}
+
+// waitFor polls until cond holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// appendFile appends content to the file at path
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordReaderGrowingLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("2024-01-02 a\ncont\n2024-0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFileReader(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.Follow(true)
+	rr := NewRecordReader(NewContainerLogReader(fr), regexp.MustCompile(`^\d{4}-\d\d-\d\d`))
+	defer rr.Close()
+	if err := rr.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The last line does not start a record yet
+	waitFor(t, "the unterminated line", func() bool {
+		line, err := rr.GetLine(0)
+		return err == nil && line == "2024-01-02 a\ncont\n2024-0"
+	})
+
+	appendFile(t, path, "1-03 b\n2024-01-04 c\n")
+	want := []string{"2024-01-02 a\ncont", "2024-01-03 b", "2024-01-04 c"}
+	waitFor(t, "the records", func() bool {
+		lines, err := rr.GetLines(0, 3)
+		return err == nil && reflect.DeepEqual(lines, want)
+	})
+	if count := rr.LineCount(); count != 3 {
+		t.Errorf("LineCount() = %d, want 3", count)
+	}
+}
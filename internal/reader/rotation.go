@@ -0,0 +1,99 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkRotation detects a followed file being truncated or, when
+// following by name, replaced by a new file. It starts a new segment
+// after a marker line and returns true if it did so.
+func (fr *FileReader) checkRotation(buf []byte) bool {
+	seg := fr.current()
+
+	info, err := seg.file.Stat()
+	if err != nil {
+		return false
+	}
+
+	// copytruncate: the content we indexed is gone, start over after it.
+	// Its lines keep their place so line numbers do not change.
+	if info.Size() < seg.index.size {
+		fr.mu.Lock()
+		kept := fr.cache.take(seg)
+		if _, ok := kept[seg.lastNum]; !ok && seg.last != nil {
+			kept[seg.lastNum] = seg.last
+		}
+		last := len(fr.segments) - 1
+		fr.segments = append(fr.segments[:last:last],
+			&segment{index: seg.index, kept: kept},
+			&segment{marker: rotationMarker(fmt.Sprintf("%s truncated", fr.filename))},
+			&segment{file: seg.file, index: newLineIndex(fr.split)})
+		fr.mu.Unlock()
+
+		fr.notify()
+		return true
+	}
+
+	fr.mu.Lock()
+	byName := fr.byName
+	fr.mu.Unlock()
+
+	if !byName {
+		return false
+	}
+
+	// The name may briefly not exist between rename and re-creation
+	pathInfo, err := os.Stat(fr.path)
+	if err != nil || os.SameFile(info, pathInfo) {
+		return false
+	}
+
+	f, err := os.Open(fr.path)
+	if err != nil {
+		return false
+	}
+
+	// Pick up anything written to the old file before it was renamed
+	for {
+		if _, err := fr.readChunk(seg, buf); err != nil {
+			break
+		}
+	}
+
+	fr.mu.Lock()
+	fr.segments = append(fr.segments,
+		&segment{marker: rotationMarker(fmt.Sprintf("%s rotated, reopened", fr.filename))},
+		&segment{file: f, index: newLineIndex(fr.split)})
+	fr.mu.Unlock()
+
+	fr.notify()
+	return true
+}
+
+// truncatedLine is shown for lines of a truncated file that were not
+// cached, like less shows lines past the end of a file
+const truncatedLine = "\x1b[2m~\x1b[0m"
+
+// truncatedLines returns the lines [start, end) of a file truncated in
+// place, as far as they are still cached
+func truncatedLines(seg *segment, start, end int) []string {
+	end = min(end, seg.lines())
+	result := make([]string, 0, max(end-start, 0))
+	for i := start; i < end; i++ {
+		line := truncatedLine
+		if b, ok := seg.kept[i/indexStride]; ok {
+			if _, long := b.long[i%indexStride]; !long && i%indexStride < len(b.lines) {
+				line = b.lines[i%indexStride]
+			}
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// rotationMarker returns the line shown where the followed file changed
+func rotationMarker(event string) string {
+	return fmt.Sprintf("\x1b[7m--- %s at %s ---\x1b[0m", event, time.Now().Format("15:04:05"))
+}
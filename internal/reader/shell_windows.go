@@ -0,0 +1,9 @@
+//go:build windows
+
+package reader
+
+// shell and shellFlag run the commands given with --cmd
+const (
+	shell     = "cmd"
+	shellFlag = "/C"
+)
@@ -0,0 +1,173 @@
+package reader
+
+// LineSource is content that can be shown line by line. Sources load in
+// the background: LineCount grows as lines become available and Updates
+// signals each change.
+type LineSource interface {
+	// Load starts reading the content and returns without waiting for it
+	Load() error
+
+	// LineCount returns the number of lines available so far
+	LineCount() int
+
+	// GetLine returns the line at the specified index (0-based)
+	GetLine(index int) (string, error)
+
+	// GetLines returns a range of lines [start, end)
+	GetLines(start, end int) ([]string, error)
+
+	// Filename returns the name shown for the source
+	Filename() string
+
+	// Updates returns a channel that receives a value whenever the
+	// content changes
+	Updates() <-chan struct{}
+
+	// Loading reports whether more lines are expected
+	Loading() bool
+
+	// Err returns the error that stopped loading, if any
+	Err() error
+
+	// Close releases the resources held by the source
+	Close() error
+}
+
+// Follower is implemented by sources that can watch their input grow
+type Follower interface {
+	Follow(enabled bool)
+}
+
+// Match is a search result: Length characters of the line, starting at
+// byte Index of the line without ANSI codes
+type Match struct {
+	Line   int
+	Index  int
+	Length int
+}
+
+// Searcher is implemented by sources with their own way of searching,
+// such as hex dumps matching byte patterns
+type Searcher interface {
+	Search(term string) ([]Match, error)
+}
+
+// HexDumper is implemented by sources that can show their raw content
+type HexDumper interface {
+	// Binary reports whether the content looks like binary data
+	Binary() bool
+
+	// HexDump returns a hex dump view of the content
+	HexDump() (LineSource, error)
+}
+
+// CRLFSource is implemented by sources that strip CRLF line endings
+type CRLFSource interface {
+	// CRLF reports whether lines were found ending in CRLF
+	CRLF() bool
+}
+
+// Separator is implemented by sources whose lines were split on
+// something other than a newline, or end in CRLF
+type Separator interface {
+	// Separator returns what ends each line when it is written back out
+	Separator() string
+
+	// Terminated reports whether the last line ends in the separator
+	// too, as lines of a text file usually do
+	Terminated() bool
+}
+
+// Chooser is implemented by sources listing other content to open, such
+// as the members of an archive
+type Chooser interface {
+	// Choose opens the content listed on a line
+	Choose(line int) (LineSource, error)
+}
+
+// Reloader is implemented by sources that can read their input again
+type Reloader interface {
+	Reload() error
+}
+
+// Gutter is implemented by sources that flag some of their lines
+type Gutter interface {
+	// Gutter returns the marker shown before a line, "" for none
+	Gutter(index int) string
+
+	// HasGutter reports whether lines can be flagged, so a column is
+	// reserved for the markers
+	HasGutter() bool
+}
+
+// PrefixToggler is implemented by sources showing metadata before each
+// line, such as the time and stream of container log messages
+type PrefixToggler interface {
+	// TogglePrefix shows or hides the prefix, returning false if there
+	// is none
+	TogglePrefix() bool
+}
+
+// CacheReporter is implemented by sources caching lines read from disk
+type CacheReporter interface {
+	CacheStats() CacheStats
+}
+
+// Tailer is implemented by sources that can show their end before they
+// are completely loaded
+type Tailer interface {
+	// Tail returns the last n lines
+	Tail(n int) ([]string, error)
+
+	// EstimatedLines estimates the line count once loaded
+	EstimatedLines() int
+}
+
+// Previewer is implemented by sources that can read just the start of
+// very long lines, enough to fill the screen
+type Previewer interface {
+	// GetLinePrefixes returns a range of lines [start, end), reading no
+	// more than limit bytes of lines too long to be cached
+	GetLinePrefixes(start, end, limit int) ([]string, error)
+}
+
+// MultiLine is implemented by sources whose lines can span several
+// rows, such as log records with their continuation lines
+type MultiLine interface {
+	MultiLine() bool
+}
+
+var (
+	_ LineSource    = (*FileReader)(nil)
+	_ LineSource    = (*MergeReader)(nil)
+	_ LineSource    = (*HexReader)(nil)
+	_ LineSource    = (*ArchiveReader)(nil)
+	_ LineSource    = (*CommandReader)(nil)
+	_ LineSource    = (*ListenReader)(nil)
+	_ LineSource    = (*ContainerLogReader)(nil)
+	_ Follower      = (*FileReader)(nil)
+	_ Follower      = (*ContainerLogReader)(nil)
+	_ HexDumper     = (*FileReader)(nil)
+	_ HexDumper     = (*ContainerLogReader)(nil)
+	_ CRLFSource    = (*FileReader)(nil)
+	_ Separator     = (*FileReader)(nil)
+	_ Searcher      = (*HexReader)(nil)
+	_ Chooser       = (*ArchiveReader)(nil)
+	_ Reloader      = (*CommandReader)(nil)
+	_ Gutter        = (*CommandReader)(nil)
+	_ Gutter        = (*FileReader)(nil)
+	_ Gutter        = (*ContainerLogReader)(nil)
+	_ PrefixToggler = (*ContainerLogReader)(nil)
+	_ CacheReporter = (*FileReader)(nil)
+	_ CacheReporter = (*ContainerLogReader)(nil)
+	_ Tailer        = (*FileReader)(nil)
+	_ Tailer        = (*ContainerLogReader)(nil)
+	_ LineSource    = (*RecordReader)(nil)
+	_ MultiLine     = (*RecordReader)(nil)
+	_ Previewer     = (*FileReader)(nil)
+	_ Previewer     = (*ContainerLogReader)(nil)
+	_ Previewer     = (*RecordReader)(nil)
+	_ Tailer        = (*RecordReader)(nil)
+	_ Gutter        = (*RecordReader)(nil)
+	_ PrefixToggler = (*RecordReader)(nil)
+)
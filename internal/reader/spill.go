@@ -0,0 +1,80 @@
+package reader
+
+// spill holds stream content in a temporary file once it outgrows the
+// memory budget, indexed like a regular file. Lines are written in
+// batches and become visible once written.
+type spill struct {
+	seg     *segment
+	sep     string // Written after each line
+	pending []byte // Lines not yet written to the file
+}
+
+// spillStream moves the lines read so far to a temporary file, from which
+// lines are read on demand from then on
+func (fr *FileReader) spillStream() (*spill, error) {
+	f, err := createSpillFile()
+	if err != nil {
+		return nil, err
+	}
+	s := &spill{
+		seg: &segment{file: f, index: newLineIndex(fr.split)},
+		sep: fr.split.separator(),
+	}
+
+	// Only the stream goroutine appends lines, so they can be read unlocked.
+	// The segment is not shared yet, so neither needs its index.
+	for _, line := range fr.lines {
+		s.add(line)
+		if len(s.pending) >= indexChunkSize {
+			if err := s.write(); err != nil {
+				f.Close()
+				return nil, err
+			}
+			s.seg.index.add(s.pending)
+			s.pending = s.pending[:0]
+		}
+	}
+	if err := s.write(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.seg.index.add(s.pending)
+	s.pending = s.pending[:0]
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.segments = []*segment{s.seg}
+	fr.lines = nil
+
+	return s, nil
+}
+
+// add queues a line to be written
+func (s *spill) add(line string) {
+	s.pending = append(s.pending, line...)
+	s.pending = append(s.pending, s.sep...)
+}
+
+// write appends the queued lines to the file
+func (s *spill) write() error {
+	_, err := s.seg.file.Write(s.pending)
+	return err
+}
+
+// flushSpill writes the queued lines and makes them available
+func (fr *FileReader) flushSpill(s *spill) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if err := s.write(); err != nil {
+		return err
+	}
+
+	fr.mu.Lock()
+	s.seg.index.add(s.pending)
+	fr.mu.Unlock()
+
+	s.pending = s.pending[:0]
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package reader
+
+import "os"
+
+// createSpillFile creates the temporary file holding stream content.
+// It is removed right away and lives on only while open, so it is gone
+// once gless exits, however it exits.
+func createSpillFile() (*os.File, error) {
+	f, err := os.CreateTemp("", "gless-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
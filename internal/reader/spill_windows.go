@@ -0,0 +1,45 @@
+//go:build windows
+
+package reader
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	// fileAttributeTemporary keeps the file in the system cache if possible
+	fileAttributeTemporary = 0x100
+
+	// fileFlagDeleteOnClose makes Windows delete a file once its last
+	// handle is closed, which also happens when the process is killed
+	fileFlagDeleteOnClose = 0x04000000
+)
+
+// createSpillFile creates the temporary file holding stream content.
+// Open files cannot be removed on Windows, so it is reopened to be
+// deleted by the system once gless exits, however it exits.
+func createSpillFile() (*os.File, error) {
+	f, err := os.CreateTemp("", "gless-*")
+	if err != nil {
+		return nil, err
+	}
+	name := f.Name()
+	f.Close()
+
+	path, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		os.Remove(name)
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(path,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING,
+		fileAttributeTemporary|fileFlagDeleteOnClose, 0)
+	if err != nil {
+		os.Remove(name)
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), name), nil
+}
@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+// splitter splits content into records, each shown as one line. By
+// default records end in a newline, optionally preceded by a carriage
+// return. They can instead end in any byte sequence, such as the NUL of
+// find -print0, or have a fixed length without any separator.
+type splitter struct {
+	delim  []byte // Bytes ending each record
+	length int    // Fixed record length, used instead of delim if set
+}
+
+// newlines is the default splitter, for lines ending in LF or CRLF
+var newlines = &splitter{delim: []byte("\n")}
+
+// newSplitter creates the splitter for the given options
+func newSplitter(delimiter string, length int) (*splitter, error) {
+	switch {
+	case length < 0:
+		return nil, errors.New("record length must be positive")
+	case length > 0 && delimiter != "":
+		return nil, errors.New("records have either a delimiter or a fixed length")
+	case length > 0:
+		return &splitter{length: length}, nil
+	case delimiter == "" || delimiter == "\n":
+		return newlines, nil
+	}
+	return &splitter{delim: []byte(delimiter)}, nil
+}
+
+// newline reports whether records are newline-terminated lines
+func (s *splitter) newline() bool {
+	return s == newlines
+}
+
+// separator returns what follows each record when records are written
+// back out
+func (s *splitter) separator() string {
+	return string(s.delim)
+}
+
+// trim removes the delimiter ending a record
+func (s *splitter) trim(record string) string {
+	if s.newline() {
+		return trimEOL(record)
+	}
+	return strings.TrimSuffix(record, string(s.delim))
+}
+
+// next returns the end offset of the first record ending in data[from:],
+// or -1 if none does
+func (s *splitter) next(data []byte, from int) int {
+	if s.length > 0 {
+		if from+s.length > len(data) {
+			return -1
+		}
+		return from + s.length
+	}
+
+	i := bytes.Index(data[from:], s.delim)
+	if i < 0 {
+		return -1
+	}
+	return from + i + len(s.delim)
+}
+
+// readRecord reads the next record including its delimiter, however long
+// it is. Records longer than limit are skipped: a nil slice is returned
+// along with the number of bytes consumed.
+func (s *splitter) readRecord(br *bufio.Reader, limit int) ([]byte, int, error) {
+	if s.length > 0 {
+		return s.readFixed(br, limit)
+	}
+
+	var record []byte
+	var end []byte // Last bytes read, to spot a delimiter in a skipped record
+	length := 0
+	skipped := false
+	last := s.delim[len(s.delim)-1]
+
+	for {
+		frag, err := br.ReadSlice(last)
+		length += len(frag)
+		if !skipped {
+			if length > limit {
+				skipped = true
+				record = nil
+			} else {
+				record = append(record, frag...)
+			}
+		}
+
+		if len(s.delim) > 1 {
+			end = append(end, frag[max(len(frag)-len(s.delim), 0):]...)
+			end = end[max(len(end)-len(s.delim), 0):]
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		// A multi-byte delimiter only ends the record if all of it was read
+		if err == nil && len(s.delim) > 1 && !bytes.Equal(end, s.delim) {
+			continue
+		}
+		return record, length, err
+	}
+}
+
+// readFixed reads a fixed-length record, which is shorter only at EOF
+func (s *splitter) readFixed(br *bufio.Reader, limit int) ([]byte, int, error) {
+	if s.length > limit {
+		n, err := br.Discard(s.length)
+		if err == nil && n < s.length {
+			err = io.EOF
+		}
+		return nil, n, err
+	}
+
+	record := make([]byte, s.length)
+	n, err := io.ReadFull(br, record)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return record[:n], n, err
+}
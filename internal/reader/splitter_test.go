@@ -0,0 +1,229 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// splitRecords splits content the simple way, as the reference for the
+// chunked and buffered splitting
+func splitRecords(content string, split *splitter) []string {
+	var records []string
+	if split.length > 0 {
+		for len(content) > 0 {
+			n := min(split.length, len(content))
+			records = append(records, content[:n])
+			content = content[n:]
+		}
+		return records
+	}
+
+	delim := string(split.delim)
+	for content != "" {
+		record, rest, found := strings.Cut(content, delim)
+		if !found {
+			return append(records, record)
+		}
+		records = append(records, split.trim(record+delim))
+		content = rest
+	}
+	return records
+}
+
+// splitterCases covers the kinds of splitting, with records of every
+// length around the delimiter and a last record with or without one
+var splitterCases = []struct {
+	name    string
+	split   *splitter
+	content string
+}{
+	{"newlines", newlines, "one\ntwo\r\n\nthree"},
+	{"newlines ending in newline", newlines, "one\ntwo\n"},
+	{"NUL", &splitter{delim: []byte{0}}, "a\x00bb\x00\x00ccc\x00"},
+	{"multi-byte delimiter", &splitter{delim: []byte("<|>")}, "a<|>b<<|>|c<|><|><<||>d<|"},
+	{"repeated delimiter bytes", &splitter{delim: []byte("aab")}, "xaaabyaabaab"},
+	{"fixed length", &splitter{length: 4}, "AAAABBBBCCCCDD"},
+	{"fixed length without rest", &splitter{length: 3}, "abcdefghi"},
+}
+
+func TestLineIndexChunks(t *testing.T) {
+	for _, tt := range splitterCases {
+		want := splitRecords(tt.content, tt.split)
+
+		// Every chunk size, so delimiters and records are split everywhere
+		for size := 1; size <= len(tt.content); size++ {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, size), func(t *testing.T) {
+				idx := newLineIndex(tt.split)
+				for i := 0; i < len(tt.content); i += size {
+					idx.add([]byte(tt.content[i:min(i+size, len(tt.content))]))
+				}
+				idx.eof = true
+
+				if idx.lines() != len(want) {
+					t.Fatalf("lines() = %d, want %d", idx.lines(), len(want))
+				}
+				r := strings.NewReader(tt.content)
+				b, err := readBlock(r, 0, idx.size, idx.lines(), tt.split)
+				if err != nil {
+					t.Fatalf("readBlock: %v", err)
+				}
+				if !reflect.DeepEqual(b.lines, want) {
+					t.Errorf("lines = %q, want %q", b.lines, want)
+				}
+			})
+		}
+	}
+}
+
+func TestLineIndexUnterminated(t *testing.T) {
+	idx := newLineIndex(&splitter{delim: []byte("<|>")})
+	idx.add([]byte("a<|>b<"))
+
+	// The last record is only counted at EOF, it may still grow
+	if idx.lines() != 1 {
+		t.Errorf("lines() before EOF = %d, want 1", idx.lines())
+	}
+	idx.add([]byte("|>c"))
+	if idx.lines() != 2 || idx.end != 8 {
+		t.Errorf("lines() = %d, end = %d, want 2 and 8", idx.lines(), idx.end)
+	}
+	idx.eof = true
+	if idx.lines() != 3 {
+		t.Errorf("lines() at EOF = %d, want 3", idx.lines())
+	}
+}
+
+func TestLineIndexCheckpoints(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 3*indexStride+10; i++ {
+		fmt.Fprintf(&content, "line %d<|>", i)
+	}
+	split := &splitter{delim: []byte("<|>")}
+
+	idx := newLineIndex(split)
+	data := []byte(content.String())
+	for i := 0; i < len(data); i += 1000 {
+		idx.add(data[i:min(i+1000, len(data))])
+	}
+
+	if len(idx.checkpoints) != 4 {
+		t.Fatalf("%d checkpoints, want 4", len(idx.checkpoints))
+	}
+	for blockNum, offset := range idx.checkpoints {
+		b, err := readBlock(bytes.NewReader(data), offset, idx.size, 1, split)
+		if err != nil {
+			t.Fatalf("readBlock: %v", err)
+		}
+		if want := fmt.Sprintf("line %d", blockNum*indexStride); len(b.lines) != 1 || b.lines[0] != want {
+			t.Errorf("block %d starts with %q, want %q", blockNum, b.lines, want)
+		}
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	for _, tt := range splitterCases {
+		t.Run(tt.name, func(t *testing.T) {
+			want := splitRecords(tt.content, tt.split)
+
+			// The smallest buffer, so records and delimiters span reads
+			br := bufio.NewReaderSize(strings.NewReader(tt.content), 16)
+			var got []string
+			consumed := 0
+			for {
+				record, length, err := tt.split.readRecord(br, 1024)
+				if length > 0 {
+					got = append(got, tt.split.trim(string(record)))
+				}
+				consumed += length
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("readRecord: %v", err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("records = %q, want %q", got, want)
+			}
+			if consumed != len(tt.content) {
+				t.Errorf("consumed %d bytes, want %d", consumed, len(tt.content))
+			}
+		})
+	}
+}
+
+func TestReadRecordLong(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	tests := []struct {
+		name  string
+		split *splitter
+		input string
+	}{
+		{"newline", newlines, long + "\nshort\n"},
+		{"multi-byte delimiter", &splitter{delim: []byte("<|>")}, long + "<|>short<|>"},
+		{"delimiter split across reads", &splitter{delim: []byte("<|>")}, strings.Repeat("x", 15) + "<|>short<|>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReaderSize(strings.NewReader(tt.input), 16)
+			first := strings.Index(tt.input, string(tt.split.delim)) + len(tt.split.delim)
+
+			// A record over the limit is skipped, but its bytes are counted
+			record, length, err := tt.split.readRecord(br, 10)
+			if err != nil || record != nil || length != first {
+				t.Fatalf("readRecord = %q, %d, %v; want nil, %d, nil", record, length, err, first)
+			}
+
+			record, _, err = tt.split.readRecord(br, 10)
+			if err != nil || tt.split.trim(string(record)) != "short" {
+				t.Errorf("next record = %q, %v; want \"short\"", record, err)
+			}
+		})
+	}
+}
+
+func TestReadFixedLong(t *testing.T) {
+	split := &splitter{length: 8}
+	br := bufio.NewReader(strings.NewReader("AAAAAAAABBB"))
+
+	record, length, err := split.readRecord(br, 4)
+	if record != nil || length != 8 || err != nil {
+		t.Errorf("readRecord = %q, %d, %v; want nil, 8, nil", record, length, err)
+	}
+	record, length, err = split.readRecord(br, 4)
+	if record != nil || length != 3 || err != io.EOF {
+		t.Errorf("readRecord = %q, %d, %v; want nil, 3, EOF", record, length, err)
+	}
+}
+
+func TestNewSplitter(t *testing.T) {
+	tests := []struct {
+		delimiter string
+		length    int
+		wantErr   bool
+		newline   bool
+	}{
+		{"", 0, false, true},
+		{"\n", 0, false, true},
+		{"\x00", 0, false, false},
+		{"", 80, false, false},
+		{"\x00", 80, true, false},
+		{"", -1, true, false},
+	}
+	for _, tt := range tests {
+		split, err := newSplitter(tt.delimiter, tt.length)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newSplitter(%q, %d) error = %v, want error: %v", tt.delimiter, tt.length, err, tt.wantErr)
+			continue
+		}
+		if err == nil && split.newline() != tt.newline {
+			t.Errorf("newSplitter(%q, %d).newline() = %v, want %v", tt.delimiter, tt.length, split.newline(), tt.newline)
+		}
+	}
+}
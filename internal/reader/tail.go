@@ -0,0 +1,138 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// tailChunkSize is the number of bytes read at a time going backwards
+	tailChunkSize = 64 * 1024
+
+	// maxTailBytes limits how far back the last lines are looked for
+	maxTailBytes = 64 * 1024 * 1024
+)
+
+// Tail returns the last n lines of the file, read backwards from its end
+// without waiting for the index
+func (fr *FileReader) Tail(n int) ([]string, error) {
+	fr.mu.Lock()
+	if fr.stream != nil {
+		fr.mu.Unlock()
+		return nil, errors.New("the end of a stream is only known once it is read")
+	}
+	seg := fr.segments[len(fr.segments)-1]
+	split := fr.split
+	decode := fr.decode
+	fr.mu.Unlock()
+
+	info, err := seg.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := info.Size()
+
+	var lines []string
+	if split.length > 0 {
+		lines, err = tailRecords(seg.file, end, n, split.length)
+	} else {
+		lines, err = tailLines(seg.file, end, n, split)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range lines {
+		lines[i] = decode(line)
+	}
+	return lines, nil
+}
+
+// tailLines returns the last n lines before end, split on delimiters
+func tailLines(f *os.File, end int64, n int, split *splitter) ([]string, error) {
+	// Collect chunks from the end until they hold n delimiters before the
+	// last line
+	var data []byte
+	breaks := 0
+	offset := end
+	for offset > 0 && end-offset < maxTailBytes {
+		size := min(tailChunkSize, offset)
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		if offset+size == end {
+			chunk = bytes.TrimSuffix(chunk, split.delim)
+		}
+		breaks += bytes.Count(chunk, split.delim)
+		data = append(chunk, data...)
+
+		if breaks >= n {
+			break
+		}
+	}
+
+	// The first line may be cut if maxTailBytes was reached
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+	lines := strings.Split(string(data), string(split.delim))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	if split.newline() {
+		for i, line := range lines {
+			lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	return lines, nil
+}
+
+// tailRecords returns the last n fixed-length records before end
+func tailRecords(f *os.File, end int64, n, length int) ([]string, error) {
+	// Records start at multiples of the length, the last may be shorter
+	records := (end + int64(length) - 1) / int64(length)
+	n = min(n, max(maxTailBytes/length, 1))
+	start := max(records-int64(n), 0) * int64(length)
+
+	data := make([]byte, end-start)
+	if _, err := f.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	lines := make([]string, 0, n)
+	for pos := 0; pos < len(data); pos += length {
+		lines = append(lines, string(data[pos:min(pos+length, len(data))]))
+	}
+	return lines, nil
+}
+
+// EstimatedLines estimates the number of lines of the file from the
+// average length of the lines indexed so far
+func (fr *FileReader) EstimatedLines() int {
+	count := fr.LineCount()
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.stream != nil {
+		return count
+	}
+	seg := fr.segments[len(fr.segments)-1]
+	if seg.file == nil || seg.index.eof || seg.index.end == 0 {
+		return count
+	}
+
+	info, err := seg.file.Stat()
+	if err != nil {
+		return count
+	}
+	rest := info.Size() - seg.index.end
+	return count + int(rest*int64(seg.index.count)/seg.index.end)
+}
@@ -0,0 +1,44 @@
+package reader
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/iqoologic/gless/internal/ansi"
+)
+
+// timestampRegex matches a timestamp at the start of a line, optionally in
+// brackets: ISO 8601 style dates with a space or T separator, or syslog
+// style dates without a year
+var timestampRegex = regexp.MustCompile(
+	`^\[?(\d{4}[-/]\d\d[-/]\d\d[T ]\d\d:\d\d:\d\d(?:[.,]\d+)?(?:Z|[+-]\d\d:?\d\d)?|[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d)`)
+
+// timestampLayouts are tried in order on a normalized timestamp
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"Jan _2 15:04:05",
+}
+
+// parseTimestamp returns the timestamp at the start of a line
+func parseTimestamp(line string) (time.Time, bool) {
+	match := timestampRegex.FindStringSubmatch(ansi.StripANSI(line))
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	ts := match[1]
+	if ts[0] >= '0' && ts[0] <= '9' {
+		// 2024/01/02T03:04:05 -> 2024-01-02 03:04:05
+		ts = strings.ReplaceAll(ts[:10], "/", "-") + " " + ts[11:]
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, ts); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,45 @@
+package reader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, minute, sec, nsec int) time.Time {
+		return time.Date(year, month, day, hour, minute, sec, nsec, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want time.Time
+		ok   bool
+	}{
+		{"space separator", "2024-01-02 03:04:05 INFO started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"T separator", "2024-01-02T03:04:05 started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"slashes", "2024/01/02 03:04:05 started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"milliseconds", "2024-01-02 03:04:05.123 started", utc(2024, 1, 2, 3, 4, 5, 123000000), true},
+		{"comma milliseconds", "2024-01-02 03:04:05,250 started", utc(2024, 1, 2, 3, 4, 5, 250000000), true},
+		{"Z", "2024-01-02T03:04:05Z started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"offset", "2024-01-02T05:04:05+02:00 started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"offset without colon", "2024-01-02T05:04:05+0200 started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"brackets", "[2024-01-02 03:04:05] started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"ANSI colors", "\x1b[32m2024-01-02 03:04:05\x1b[0m started", utc(2024, 1, 2, 3, 4, 5, 0), true},
+		{"syslog", "Jan  2 03:04:05 host sshd[1]: started", utc(0, 1, 2, 3, 4, 5, 0), true},
+		{"syslog two digit day", "Jan 12 03:04:05 host started", utc(0, 1, 12, 3, 4, 5, 0), true},
+		{"not at start", "INFO 2024-01-02 03:04:05 started", time.Time{}, false},
+		{"date only", "2024-01-02 started", time.Time{}, false},
+		{"invalid month", "2024-13-02 03:04:05 started", time.Time{}, false},
+		{"no timestamp", "  at com.example.Main(Main.java:1)", time.Time{}, false},
+		{"empty", "", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTimestamp(tt.line)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("parseTimestamp(%q) = %v, %v; want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package viewer
+
+import (
+	"github.com/iqoologic/gless/internal/reader"
+)
+
+// fileState is the view state remembered for each open file
+type fileState struct {
+	source        reader.LineSource
+	currentLine   int
+	rowOffset     int
+	searchTerm    string
+	searchResults []SearchMatch
+	currentResult int
+	alt           *fileState
+	binaryChecked bool
+	selected      int
+	parent        *fileState
+}
+
+// saveState returns the view state of the current file
+func (v *Viewer) saveState() fileState {
+	return fileState{
+		source:        v.source,
+		currentLine:   v.currentLine,
+		rowOffset:     v.rowOffset,
+		searchTerm:    v.searchTerm,
+		searchResults: v.searchResults,
+		currentResult: v.currentResult,
+		alt:           v.alt,
+		binaryChecked: v.binaryChecked,
+		selected:      v.selected,
+		parent:        v.parent,
+	}
+}
+
+// restoreState shows a file in a previously saved state
+func (v *Viewer) restoreState(state fileState) {
+	v.source = state.source
+	v.currentLine = state.currentLine
+	v.rowOffset = state.rowOffset
+	v.searchTerm = state.searchTerm
+	v.searchResults = state.searchResults
+	v.currentResult = state.currentResult
+	v.alt = state.alt
+	v.binaryChecked = state.binaryChecked
+	v.selected = state.selected
+	v.parent = state.parent
+	v.tailing = false
+
+	v.source.Load()
+}
+
+// switchFile saves the state of the current file and shows another one
+func (v *Viewer) switchFile(index int) {
+	if index < 0 || index >= len(v.files) || index == v.currentFile {
+		return
+	}
+
+	if v.following {
+		v.stopFollowing()
+	}
+
+	v.files[v.currentFile] = v.saveState()
+	v.currentFile = index
+	v.restoreState(v.files[index])
+	v.checkBinary()
+}
+
+// nextFile shows the next file in the list
+func (v *Viewer) nextFile() {
+	v.switchFile(v.currentFile + 1)
+}
+
+// previousFile shows the previous file in the list
+func (v *Viewer) previousFile() {
+	v.switchFile(v.currentFile - 1)
+}
+
+// toggleHex switches the current file between its text and hex dump
+// views, each keeping its own position and search
+func (v *Viewer) toggleHex() {
+	if v.alt == nil {
+		dumper, ok := v.source.(reader.HexDumper)
+		if !ok {
+			v.message = "Hex view is not available here"
+			return
+		}
+
+		hex, err := dumper.HexDump()
+		if err != nil {
+			v.message = err.Error()
+			return
+		}
+		v.alt = &fileState{source: hex, currentResult: -1, binaryChecked: true}
+	}
+
+	if v.following {
+		v.stopFollowing()
+	}
+
+	current := v.saveState()
+	alt := *v.alt
+	current.alt = nil
+	v.restoreState(alt)
+	v.alt = &current
+}
+
+// checkBinary warns once per file about binary content, switching to the
+// hex dump when there is one. Streams are only checked once data arrives.
+func (v *Viewer) checkBinary() {
+	if v.binaryChecked {
+		return
+	}
+
+	dumper, ok := v.source.(reader.HexDumper)
+	if !ok {
+		v.binaryChecked = true
+		return
+	}
+	if !dumper.Binary() {
+		if !v.source.Loading() || v.source.LineCount() > 0 {
+			v.binaryChecked = true
+		}
+		return
+	}
+
+	v.binaryChecked = true
+	if v.alt != nil {
+		return
+	}
+
+	v.toggleHex()
+	if v.alt == nil {
+		v.message = "Binary content: " + v.message
+		return
+	}
+	v.message = "Binary file, showing hex dump (x toggles)"
+}
+
+// chooser returns the current source if it lists content to open
+func (v *Viewer) chooser() (reader.Chooser, bool) {
+	c, ok := v.source.(reader.Chooser)
+	return c, ok
+}
+
+// moveSelection moves the selected line of a list, scrolling to keep it
+// on screen
+func (v *Viewer) moveSelection(delta int) {
+	v.selected = max(0, min(v.selected+delta, v.source.LineCount()-1))
+
+	if v.selected < v.currentLine {
+		v.currentLine = v.selected
+	}
+	if v.selected > v.currentLine+v.height-2 {
+		v.currentLine = v.selected - (v.height - 2)
+	}
+}
+
+// openSelected opens the content on the selected line of a list. The list
+// keeps its state and is shown again by closeSelected.
+func (v *Viewer) openSelected() {
+	c, ok := v.chooser()
+	if !ok || v.selected >= v.source.LineCount() {
+		return
+	}
+
+	source, err := c.Choose(v.selected)
+	if err != nil {
+		v.message = err.Error()
+		return
+	}
+
+	if v.following {
+		v.stopFollowing()
+	}
+
+	list := v.saveState()
+	v.restoreState(fileState{source: source, currentResult: -1, parent: &list})
+	v.checkBinary()
+}
+
+// closeSelected closes content opened from a list and shows the list again
+func (v *Viewer) closeSelected() {
+	if v.parent == nil {
+		return
+	}
+
+	if v.following {
+		v.stopFollowing()
+	}
+
+	opened := v.source
+	v.restoreState(*v.parent)
+	opened.Close()
+}
@@ -2,27 +2,57 @@ package viewer
 
 import (
 	"fmt"
-	"os"
-
-	"golang.org/x/term"
 )
 
-// handleInput handles keyboard input
-func (v *Viewer) handleInput() error {
-	buf := make([]byte, 16)
+// keyEvent is a chunk of keyboard input or the error that ended it
+type keyEvent struct {
+	data []byte
+	err  error
+}
 
-	for !v.quit {
-		n, err := os.Stdin.Read(buf)
+// readKeys reads keyboard input in the background so the main loop can
+// also react to new lines arriving
+func (v *Viewer) readKeys() {
+	for {
+		buf := make([]byte, 16)
+		n, err := v.tty.Read(buf)
+		v.keys <- keyEvent{data: buf[:n], err: err}
 		if err != nil {
-			return err
+			return
 		}
+	}
+}
 
-		if n == 0 {
-			continue
-		}
+// handleInput handles keyboard input and file updates
+func (v *Viewer) handleInput() error {
+	for !v.quit {
+		select {
+		case key := <-v.keys:
+			if key.err != nil {
+				return key.err
+			}
+
+			if len(key.data) == 0 {
+				continue
+			}
 
-		// Handle input
-		v.processInput(buf[:n])
+			// Any key ends follow mode
+			if v.following {
+				v.stopFollowing()
+				if len(key.data) == 1 && key.data[0] == 'F' {
+					break
+				}
+			}
+
+			// Handle input
+			v.message = ""
+			v.processInput(key.data)
+		case <-v.source.Updates():
+			v.checkBinary()
+			if v.following {
+				v.GoToLine(v.source.LineCount() - 1)
+			}
+		}
 
 		// Re-render
 		v.render()
@@ -31,15 +61,51 @@ func (v *Viewer) handleInput() error {
 	return nil
 }
 
+// readPrompt reads a line of input on the status line. It returns false
+// if the prompt was cancelled with Esc or Ctrl+C.
+func (v *Viewer) readPrompt(prompt string) (string, bool) {
+	v.showCursor()
+	defer v.hideCursor()
+
+	var input []rune
+	for {
+		fmt.Printf("\x1b[%d;1H", v.height)
+		fmt.Print("\x1b[2K")
+		fmt.Print(prompt + string(input))
+
+		key := <-v.keys
+		if key.err != nil {
+			return "", false
+		}
+
+		for _, r := range string(key.data) {
+			switch r {
+			case '\r', '\n':
+				return string(input), true
+			case 0x1b, 0x03: // Esc, Ctrl+C
+				return "", false
+			case 0x7f, 0x08: // Backspace
+				if len(input) > 0 {
+					input = input[:len(input)-1]
+				}
+			default:
+				if r >= ' ' {
+					input = append(input, r)
+				}
+			}
+		}
+	}
+}
+
 // processInput processes keyboard input
 func (v *Viewer) processInput(input []byte) {
 	// Check for escape sequences (arrow keys, etc.)
 	if len(input) >= 3 && input[0] == 0x1b && input[1] == '[' {
 		switch input[2] {
 		case 'A': // Up arrow
-			v.Scroll(-1)
+			v.lineUp()
 		case 'B': // Down arrow
-			v.Scroll(1)
+			v.lineDown()
 		case 'C': // Right arrow (could be used for horizontal scrolling)
 			// Not implemented yet
 		case 'D': // Left arrow
@@ -55,7 +121,7 @@ func (v *Viewer) processInput(input []byte) {
 		case 'H': // Home
 			v.GoToLine(0)
 		case 'F': // End
-			v.GoToLine(v.fileReader.LineCount() - 1)
+			v.GoToLine(v.source.LineCount() - 1)
 		}
 		return
 	}
@@ -71,11 +137,11 @@ func (v *Viewer) processInput(input []byte) {
 		case 'g': // Go to first line
 			v.GoToLine(0)
 		case 'G': // Go to last line
-			v.GoToLine(v.fileReader.LineCount() - 1)
+			v.GoToLine(v.source.LineCount() - 1)
 		case 'j': // Down (vim-style)
-			v.Scroll(1)
+			v.lineDown()
 		case 'k': // Up (vim-style)
-			v.Scroll(-1)
+			v.lineUp()
 		case 'd': // Half page down
 			v.Scroll((v.height - 2) / 2)
 		case 'u': // Half page up
@@ -86,12 +152,30 @@ func (v *Viewer) processInput(input []byte) {
 			v.Scroll(-(v.height - 2))
 		case '/': // Search
 			v.enterSearchMode()
+		case ':': // Command
+			v.enterCommandMode()
 		case 'n': // Next search result
 			v.nextSearchResult()
 		case 'N': // Previous search result
 			v.previousSearchResult()
+		case 'F': // Follow the file as it grows
+			v.Follow()
+		case 's': // Save the content to a file
+			v.save()
+		case '=': // File and cache info
+			v.showInfo()
+		case 'T': // Toggle time and stream prefix
+			v.togglePrefix()
+		case 'R': // Re-run the command
+			v.reload()
+		case 'x': // Toggle hex dump
+			v.toggleHex()
 		case '#': // Toggle line numbers
 			v.showLineNumbers = !v.showLineNumbers
+		case '\r', '\n': // Open the selected list entry
+			v.openSelected()
+		case 0x7f, 0x08: // Backspace - Back to the list
+			v.closeSelected()
 		case 0x1b: // Esc - Clear search
 			v.clearSearch()
 		case 0x03: // Ctrl+C
@@ -100,6 +184,24 @@ func (v *Viewer) processInput(input []byte) {
 	}
 }
 
+// lineDown moves down one line, or to the next entry of a list
+func (v *Viewer) lineDown() {
+	if _, ok := v.chooser(); ok {
+		v.moveSelection(1)
+		return
+	}
+	v.Scroll(1)
+}
+
+// lineUp moves up one line, or to the previous entry of a list
+func (v *Viewer) lineUp() {
+	if _, ok := v.chooser(); ok {
+		v.moveSelection(-1)
+		return
+	}
+	v.Scroll(-1)
+}
+
 // showHelp displays the help screen
 func (v *Viewer) showHelp() {
 	v.clearScreen()
@@ -119,6 +221,7 @@ func (v *Viewer) showHelp() {
 		"    d              Move down half page",
 		"    Home, g        Go to first line",
 		"    End, G         Go to last line",
+		"    F              Follow file as it grows (any key stops)",
 		"",
 		"  Search:",
 		"    /              Enter search mode",
@@ -126,10 +229,21 @@ func (v *Viewer) showHelp() {
 		"    N              Previous search result",
 		"    Esc            Clear search",
 		"",
+		"  Files:",
+		"    :n             Next file",
+		"    :p             Previous file",
+		"    Enter          Open the selected archive member",
+		"    Backspace      Back to the archive member list",
+		"",
 		"  Display:",
 		"    #              Toggle line numbers",
+		"    T              Toggle container log time and stream",
+		"    R              Re-run the command (--cmd)",
+		"    x              Toggle hex dump",
 		"",
 		"  Other:",
+		"    s              Save the content to a file",
+		"    =              Show line count and cache statistics",
 		"    h, ?           Show this help",
 		"    q              Quit",
 		"    Ctrl+C         Quit",
@@ -146,34 +260,15 @@ func (v *Viewer) showHelp() {
 	}
 
 	// Wait for keypress
-	buf := make([]byte, 16)
-	os.Stdin.Read(buf)
+	<-v.keys
 
 	v.clearScreen()
 }
 
 // enterSearchMode prompts for search input
 func (v *Viewer) enterSearchMode() {
-	// Show cursor and move to bottom
-	v.showCursor()
-	fmt.Printf("\x1b[%d;1H", v.height)
-	fmt.Print("\x1b[2K")
-	fmt.Print("/")
-
-	// Restore terminal for search input
-	if v.terminalState != nil {
-		term.Restore(int(os.Stdin.Fd()), v.terminalState)
-	}
-
-	// Read search term
-	var searchTerm string
-	fmt.Scanln(&searchTerm)
-
-	// Re-enter raw mode
-	v.enterRawMode()
-	v.hideCursor()
-
-	if searchTerm == "" {
+	searchTerm, ok := v.readPrompt("/")
+	if !ok || searchTerm == "" {
 		return
 	}
 
@@ -184,7 +279,7 @@ func (v *Viewer) enterSearchMode() {
 	// Jump to first result if found
 	if len(v.searchResults) > 0 {
 		v.currentResult = 0
-		v.GoToLine(v.searchResults[0].Line)
+		v.showMatch(v.searchResults[0])
 	}
 }
 
@@ -203,6 +298,21 @@ func (v *Viewer) enterSearchMode() {
 // I think I might have confused where performSearch lives.
 // Let's look at `keyboard.go` content again.
 
+// enterCommandMode prompts for a less-style colon command
+func (v *Viewer) enterCommandMode() {
+	command, ok := v.readPrompt(":")
+	if !ok {
+		return
+	}
+
+	switch command {
+	case "n": // Next file
+		v.nextFile()
+	case "p": // Previous file
+		v.previousFile()
+	}
+}
+
 // nextSearchResult jumps to the next search result
 func (v *Viewer) nextSearchResult() {
 	if len(v.searchResults) == 0 {
@@ -214,7 +324,7 @@ func (v *Viewer) nextSearchResult() {
 		v.currentResult = 0
 	}
 
-	v.GoToLine(v.searchResults[v.currentResult].Line)
+	v.showMatch(v.searchResults[v.currentResult])
 }
 
 // previousSearchResult jumps to the previous search result
@@ -228,7 +338,7 @@ func (v *Viewer) previousSearchResult() {
 		v.currentResult = len(v.searchResults) - 1
 	}
 
-	v.GoToLine(v.searchResults[v.currentResult].Line)
+	v.showMatch(v.searchResults[v.currentResult])
 }
 
 // clearSearch clears the current search
@@ -0,0 +1,144 @@
+package viewer
+
+import (
+	"strings"
+
+	"github.com/iqoologic/gless/internal/ansi"
+	"github.com/iqoologic/gless/internal/reader"
+)
+
+// screenRow is a row of the screen, showing a line or, for sources with
+// multi-line lines, part of one
+type screenRow struct {
+	text  string
+	line  int  // Index of the line shown, -1 if not known yet
+	first bool // First row of the line
+	start int  // Offset of the row in the line without ANSI codes
+}
+
+// multiLine reports whether lines of the current source can span
+// several rows
+func (v *Viewer) multiLine() bool {
+	src, ok := v.source.(reader.MultiLine)
+	return ok && src.MultiLine()
+}
+
+// screenRows splits lines into rows, leaving out the rows of the first
+// line scrolled past. first is the index of the first line, -1 if not
+// known yet.
+func (v *Viewer) screenRows(lines []string, first int) []screenRow {
+	multi := v.multiLine()
+	rows := make([]screenRow, 0, len(lines))
+	for i, line := range lines {
+		index := -1
+		if first >= 0 {
+			index = first + i
+		}
+
+		if !multi {
+			rows = append(rows, screenRow{text: line, line: index, first: true})
+			continue
+		}
+
+		start := 0
+		for j, text := range strings.Split(line, "\n") {
+			if i > 0 || j >= v.rowOffset {
+				rows = append(rows, screenRow{text: text, line: index, first: j == 0, start: start})
+			}
+			start += len(ansi.StripANSI(text)) + 1
+		}
+	}
+	return rows
+}
+
+// scrollRows scrolls a source with multi-line lines by rows, keeping
+// the screen filled at the end
+func (v *Viewer) scrollRows(delta int) {
+	v.moveRows(delta)
+
+	displayHeight := v.height - 1
+	if rows := v.rowsBelow(displayHeight); rows < displayHeight {
+		v.moveRows(rows - displayHeight)
+	}
+}
+
+// moveRows moves the top of the screen by delta rows
+func (v *Viewer) moveRows(delta int) {
+	total := v.source.LineCount()
+	v.currentLine = max(min(v.currentLine, total-1), 0)
+
+	for delta > 0 {
+		left := v.lineRows(v.currentLine) - 1 - v.rowOffset
+		if delta <= left {
+			v.rowOffset += delta
+			return
+		}
+		if v.currentLine >= total-1 {
+			v.rowOffset += left
+			return
+		}
+		delta -= left + 1
+		v.currentLine++
+		v.rowOffset = 0
+	}
+
+	for delta < 0 {
+		if -delta <= v.rowOffset {
+			v.rowOffset += delta
+			return
+		}
+		if v.currentLine == 0 {
+			v.rowOffset = 0
+			return
+		}
+		delta += v.rowOffset + 1
+		v.currentLine--
+		v.rowOffset = v.lineRows(v.currentLine) - 1
+	}
+}
+
+// rowsBelow counts the rows from the top of the screen to the end, up
+// to limit
+func (v *Viewer) rowsBelow(limit int) int {
+	lines, err := v.source.GetLines(v.currentLine, v.currentLine+limit)
+	if err != nil {
+		return limit
+	}
+
+	rows := -v.rowOffset
+	for _, line := range lines {
+		rows += strings.Count(line, "\n") + 1
+		if rows >= limit {
+			break
+		}
+	}
+	return rows
+}
+
+// lineRows returns the number of rows a line spans
+func (v *Viewer) lineRows(index int) int {
+	line, err := v.source.GetLine(index)
+	if err != nil {
+		return 1
+	}
+	return strings.Count(line, "\n") + 1
+}
+
+// showMatch moves to a search result. Matches further down a multi-line
+// line than the screen reaches are scrolled to the middle of the screen.
+func (v *Viewer) showMatch(match SearchMatch) {
+	v.GoToLine(match.Line)
+	if !v.multiLine() {
+		return
+	}
+
+	line, err := v.source.GetLine(match.Line)
+	if err != nil {
+		return
+	}
+	stripped := ansi.StripANSI(line)
+	row := strings.Count(stripped[:min(match.MatchIndex, len(stripped))], "\n")
+	if row >= v.height-1 {
+		v.scrollRows(row - (v.height-1)/2)
+	}
+}
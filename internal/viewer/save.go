@@ -0,0 +1,103 @@
+package viewer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iqoologic/gless/internal/ansi"
+	"github.com/iqoologic/gless/internal/reader"
+)
+
+// saveBatch is the number of lines read at a time when saving
+const saveBatch = 1024
+
+// save writes the content of the current file to a file named at a
+// prompt, with its ANSI escape codes kept or stripped
+func (v *Viewer) save() {
+	name, ok := v.readPrompt("Save to file: ")
+	if !ok || name == "" {
+		return
+	}
+
+	if _, err := os.Stat(name); err == nil {
+		answer, ok := v.readPrompt(fmt.Sprintf("%s exists, overwrite? (y/N) ", name))
+		if !ok || !strings.EqualFold(answer, "y") {
+			v.message = "Not saved"
+			return
+		}
+	}
+
+	answer, ok := v.readPrompt("Keep ANSI escape codes? (Y/n) ")
+	if !ok {
+		v.message = "Not saved"
+		return
+	}
+	strip := strings.EqualFold(answer, "n")
+
+	count, err := saveLines(v.source, name, strip)
+	if err != nil {
+		v.message = fmt.Sprintf("Save failed: %v", err)
+		return
+	}
+
+	v.message = fmt.Sprintf("Saved %d lines to %s", count, name)
+	if v.source.Loading() {
+		v.message += " (still loading, saved what arrived so far)"
+	}
+}
+
+// saveLines writes the lines of a source to a file, each ended the way
+// it was in the input, the last one only if it was ended. It returns the
+// number of lines written.
+func saveLines(source reader.LineSource, name string, strip bool) (int, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+
+	total := source.LineCount()
+
+	sep, terminated := "\n", true
+	if src, ok := source.(reader.Separator); ok {
+		sep, terminated = src.Separator(), src.Terminated()
+	}
+
+	// The lines of a record are joined by newlines, which stand for the
+	// separators of the input
+	multi, ok := source.(reader.MultiLine)
+	records := ok && multi.MultiLine() && sep != "\n"
+
+	count := 0
+	for count < total {
+		lines, err := source.GetLines(count, min(count+saveBatch, total))
+		if err == nil && len(lines) == 0 {
+			break
+		}
+		for i, line := range lines {
+			if strip {
+				line = ansi.StripANSI(line)
+			}
+			if records {
+				line = strings.ReplaceAll(line, "\n", sep)
+			}
+			w.WriteString(line)
+			if terminated || count+i < total-1 {
+				w.WriteString(sep)
+			}
+		}
+		count += len(lines)
+		if err != nil {
+			f.Close()
+			return count, err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return count, err
+	}
+	return count, f.Close()
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package viewer
+
+// ttyPath is the controlling terminal, used when stdin is redirected
+const ttyPath = "/dev/tty"
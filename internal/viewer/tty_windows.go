@@ -0,0 +1,6 @@
+//go:build windows
+
+package viewer
+
+// ttyPath is the console input, used when stdin is redirected
+const ttyPath = "CONIN$"
@@ -10,45 +10,78 @@ import (
 	"golang.org/x/term"
 )
 
+// maxBytesPerChar bounds the raw bytes (UTF-8 plus ANSI codes) needed to
+// draw one visible character, used to cut huge lines before parsing
+const maxBytesPerChar = 16
+
 // Viewer manages the terminal UI for viewing files
 type Viewer struct {
-	fileReader      *reader.FileReader
+	source          reader.LineSource
+	files           []fileState // All open files, state saved when switching
+	currentFile     int
 	currentLine     int // Current top line being displayed (0-based)
+	rowOffset       int // Rows of the top line scrolled past, for multi-line lines
+	lastLine        int // Line at the bottom of the screen (1-based), set by render
 	width           int
 	height          int
 	terminalState   *term.State
+	tty             *os.File // Keyboard input, stdin unless it is redirected
 	searchTerm      string
 	searchResults   []SearchMatch // All matches found
 	currentResult   int           // Index in searchResults
 	showLineNumbers bool
+	following       bool
+	tailing         bool       // Showing the end before the source is loaded
+	tailOffset      int        // Lines between the bottom of the tail view and the end
+	alt             *fileState // Other view of the file: hex dump or text
+	binaryChecked   bool       // The file was checked for binary content
+	selected        int        // Selected line when the source is a list
+	parent          *fileState // List the current content was opened from
+	message         string     // Shown in the status bar until the next key
 	quit            bool
+	keys            chan keyEvent
 }
 
 // SearchMatch represents a specific search result occurrence
 type SearchMatch struct {
 	Line       int
 	MatchIndex int // Byte index in the stripped line where match starts
+	Length     int // Length of the match in bytes
 }
 
-// NewViewer creates a new viewer for the given file
-func NewViewer(fileReader *reader.FileReader) *Viewer {
+// NewViewer creates a new viewer for the given sources. The first one is
+// shown, the others can be switched to with :n and :p.
+func NewViewer(sources ...reader.LineSource) *Viewer {
+	files := make([]fileState, len(sources))
+	for i, src := range sources {
+		files[i] = fileState{source: src, currentResult: -1}
+	}
+
 	return &Viewer{
-		fileReader:      fileReader,
+		source:          sources[0],
+		files:           files,
 		currentLine:     0,
 		searchResults:   []SearchMatch{},
 		currentResult:   -1,
 		showLineNumbers: false,
 		quit:            false,
+		keys:            make(chan keyEvent),
 	}
 }
 
 // Run starts the viewer
 func (v *Viewer) Run() error {
-	// Load file content
-	if err := v.fileReader.Load(); err != nil {
+	// Start loading file content in the background
+	if err := v.source.Load(); err != nil {
 		return fmt.Errorf("failed to load file: %w", err)
 	}
 
+	// Open the terminal for keyboard input
+	if err := v.openTerminal(); err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer v.closeTerminal()
+
 	// Enter raw mode
 	if err := v.enterRawMode(); err != nil {
 		return fmt.Errorf("failed to enter raw mode: %w", err)
@@ -62,15 +95,41 @@ func (v *Viewer) Run() error {
 
 	// Initial render
 	v.updateSize()
+	v.checkBinary()
 	v.render()
 
+	go v.readKeys()
+
 	// Main loop
 	return v.handleInput()
 }
 
+// openTerminal selects the terminal to read keys from. When the content
+// is piped in on stdin, keys are read from the controlling terminal.
+func (v *Viewer) openTerminal() error {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		v.tty = os.Stdin
+		return nil
+	}
+
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	v.tty = tty
+	return nil
+}
+
+// closeTerminal closes the terminal if it was opened by openTerminal
+func (v *Viewer) closeTerminal() {
+	if v.tty != nil && v.tty != os.Stdin {
+		v.tty.Close()
+	}
+}
+
 // enterRawMode puts the terminal into raw mode
 func (v *Viewer) enterRawMode() error {
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	oldState, err := term.MakeRaw(int(v.tty.Fd()))
 	if err != nil {
 		return err
 	}
@@ -81,16 +140,16 @@ func (v *Viewer) enterRawMode() error {
 // exitRawMode restores the terminal to normal mode
 func (v *Viewer) exitRawMode() {
 	if v.terminalState != nil {
-		term.Restore(int(os.Stdin.Fd()), v.terminalState)
+		term.Restore(int(v.tty.Fd()), v.terminalState)
 	}
 }
 
 // updateSize updates the terminal dimensions
 func (v *Viewer) updateSize() {
-	// Try Start with Stdout, fallback to Stdin
+	// Try Start with Stdout, fallback to the input terminal
 	width, height, err := term.GetSize(int(os.Stdout.Fd()))
 	if err != nil {
-		width, height, err = term.GetSize(int(os.Stdin.Fd()))
+		width, height, err = term.GetSize(int(v.tty.Fd()))
 	}
 
 	if err != nil {
@@ -125,7 +184,12 @@ func (v *Viewer) render() {
 	// Move cursor to home position
 	fmt.Print("\x1b[H")
 
-	totalLines := v.fileReader.LineCount()
+	// Line numbers are known once the source is loaded
+	if v.tailing && !v.source.Loading() {
+		v.endTail()
+	}
+
+	totalLines := v.source.LineCount()
 	displayHeight := v.height - 1 // Reserve last line for status bar
 
 	// Ensure currentLine is within bounds
@@ -145,47 +209,112 @@ func (v *Viewer) render() {
 		endLine = totalLines
 	}
 
-	lines, err := v.fileReader.GetLines(v.currentLine, endLine)
+	// No line can show more than a screenful of text
+	maxVisible := v.width * displayHeight
+
+	var lines []string
+	var err error
+	if v.tailing {
+		lines, err = v.tailLines(displayHeight)
+	} else {
+		lines, err = v.visibleLines(v.currentLine, endLine, maxVisible*maxBytesPerChar)
+	}
 	if err != nil {
 		lines = []string{fmt.Sprintf("Error reading lines: %v", err)}
 	}
 
+	gutter, ok := v.source.(reader.Gutter)
+	if ok && !gutter.HasGutter() {
+		gutter = nil
+	}
+
+	// Keep the selection of a list on screen
+	_, isList := v.chooser()
+	if isList && !v.tailing {
+		v.selected = max(v.currentLine, min(v.selected, v.currentLine+len(lines)-1))
+	}
+
+	// Lines spanning several rows may not all fit, the tail view keeps
+	// the last rows
+	first := v.currentLine
+	if v.tailing {
+		first = -1 // Not known yet
+	}
+	rows := v.screenRows(lines, first)
+	if len(rows) > displayHeight && v.tailing {
+		rows = rows[len(rows)-displayHeight:]
+	} else if len(rows) > displayHeight {
+		rows = rows[:displayHeight]
+	}
+	v.lastLine = v.currentLine
+	if len(rows) > 0 {
+		v.lastLine = rows[len(rows)-1].line + 1
+	}
+
 	// Display lines
-	for i, line := range lines {
-		lineNum := v.currentLine + i + 1 // 1-based for display
+	for i, row := range rows {
+		line := row.text
+		lineNum := row.line + 1 // 1-based for display, 0 if not known yet
 
 		// Clear line
 		fmt.Print("\x1b[2K")
 
-		// Line number prefix
-		if v.showLineNumbers {
+		// Line number prefix, only on the first row of a line
+		if v.showLineNumbers && !row.first {
+			fmt.Printf("%6s ", "")
+		} else if v.showLineNumbers && v.tailing {
+			fmt.Printf("\x1b[90m%6s\x1b[0m ", "?")
+		} else if v.showLineNumbers {
 			fmt.Printf("\x1b[90m%6d\x1b[0m ", lineNum)
 		}
 
+		// Marker column for sources that flag lines
+		if gutter != nil {
+			if marker := gutter.Gutter(lineNum - 1); marker != "" && row.first {
+				fmt.Print(marker)
+			} else {
+				fmt.Print(" ")
+			}
+		}
+
+		// Cut huge lines before parsing, leaving room for ANSI codes
+		if len(line) > maxVisible*maxBytesPerChar {
+			line = line[:maxVisible*maxBytesPerChar]
+		}
+
 		// Parse and render line with ANSI codes
 		segments := ansi.ParseLine(line)
 
 		// Apply search highlighting ONLY if this is the line containing the CURRENT match
 		if v.searchTerm != "" && v.currentResult >= 0 && v.currentResult < len(v.searchResults) {
 			currentMatch := v.searchResults[v.currentResult]
-			if currentMatch.Line == lineNum-1 {
+			if currentMatch.Line == lineNum-1 && currentMatch.MatchIndex >= row.start {
 				// Only highlight the specific occurrence
-				segments = ansi.HighlightText(segments, v.searchTerm, currentMatch.MatchIndex)
+				segments = ansi.HighlightRange(segments, currentMatch.MatchIndex-row.start, currentMatch.Length)
 			}
 		}
 
-		for _, seg := range segments {
+		// Control characters and invalid bytes must not reach the terminal
+		segments = ansi.Sanitize(segments)
+
+		if isList && lineNum-1 == v.selected {
+			fmt.Print("\x1b[7m")
+		}
+		for _, seg := range ansi.Truncate(segments, maxVisible) {
 			fmt.Print(ansi.RenderSegment(seg))
 		}
+		if isList && lineNum-1 == v.selected {
+			fmt.Print("\x1b[0m")
+		}
 
 		// Move to next line if not the last line we're rendering
-		if i < len(lines)-1 || i < displayHeight-1 {
+		if i < len(rows)-1 || i < displayHeight-1 {
 			fmt.Print("\r\n")
 		}
 	}
 
 	// Fill remaining lines if file is shorter than screen
-	for i := len(lines); i < displayHeight; i++ {
+	for i := len(rows); i < displayHeight; i++ {
 		fmt.Print("\x1b[2K")
 		fmt.Print("~")
 		if i < displayHeight-1 {
@@ -200,27 +329,71 @@ func (v *Viewer) render() {
 	os.Stdout.Sync()
 }
 
+// visibleLines returns the lines [start, end), reading only as much of
+// very long lines as can be shown if the source allows it
+func (v *Viewer) visibleLines(start, end, limit int) ([]string, error) {
+	if src, ok := v.source.(reader.Previewer); ok {
+		return src.GetLinePrefixes(start, end, limit)
+	}
+	return v.source.GetLines(start, end)
+}
+
 // renderStatusBar renders the status bar at the bottom
 func (v *Viewer) renderStatusBar() {
 	fmt.Print("\r\n")
 	fmt.Print("\x1b[2K") // Clear line
 	fmt.Print("\x1b[7m") // Reverse video
 
-	totalLines := v.fileReader.LineCount()
-	filename := v.fileReader.Filename()
+	totalLines := v.source.LineCount()
+	filename := v.source.Filename()
 
 	var percentage int
 	if totalLines > 0 {
 		percentage = ((v.currentLine + 1) * 100) / totalLines
 	}
 
+	if len(v.files) > 1 {
+		filename += fmt.Sprintf(" (file %d/%d)", v.currentFile+1, len(v.files))
+	}
+	if src, ok := v.source.(reader.CRLFSource); ok && src.CRLF() {
+		filename += " [CRLF]"
+	}
+
 	status := fmt.Sprintf(" %s | Line %d-%d/%d (%d%%)",
 		filename,
 		v.currentLine+1,
-		min(v.currentLine+v.height-1, totalLines),
+		v.lastLine,
 		totalLines,
 		percentage)
 
+	// Until the end is indexed, its line numbers are estimates
+	if v.tailing {
+		estimated := totalLines
+		if tailer, ok := v.source.(reader.Tailer); ok {
+			estimated = max(tailer.EstimatedLines(), totalLines)
+		}
+		last := max(estimated-v.tailOffset, 0)
+		if estimated == 0 {
+			status = fmt.Sprintf(" %s | Line ?/?", filename)
+		} else {
+			status = fmt.Sprintf(" %s | Line ~%d-%d/~%d",
+				filename, max(last-(v.height-2), 1), last, estimated)
+		}
+	}
+
+	if v.source.Loading() {
+		status += " (loading...)"
+	}
+
+	// Reading happens in the background, so errors are reported here
+	if err := v.source.Err(); err != nil {
+		status += fmt.Sprintf(" | Error: %v", err)
+	}
+
+	if v.following {
+		status += " | Following (any key to stop)"
+	}
+
 	// Add search info if searching
 	if v.searchTerm != "" {
 		if len(v.searchResults) > 0 {
@@ -233,6 +406,16 @@ func (v *Viewer) renderStatusBar() {
 		}
 	}
 
+	if _, ok := v.chooser(); ok {
+		status += " | Enter to open"
+	} else if v.parent != nil {
+		status += " | Backspace for list"
+	}
+
+	if v.message != "" {
+		status += " | " + v.message
+	}
+
 	// Add help hint
 	status += " | Press 'h' for help, 'q' to quit"
 
@@ -255,8 +438,17 @@ func (v *Viewer) renderStatusBar() {
 
 // Scroll scrolls the view by the specified number of lines
 func (v *Viewer) Scroll(delta int) {
+	if v.tailing {
+		v.tailOffset = max(v.tailOffset-delta, 0)
+		return
+	}
+	if v.multiLine() {
+		v.scrollRows(delta)
+		return
+	}
+
 	v.currentLine += delta
-	totalLines := v.fileReader.LineCount()
+	totalLines := v.source.LineCount()
 
 	if v.currentLine < 0 {
 		v.currentLine = 0
@@ -270,9 +462,123 @@ func (v *Viewer) Scroll(delta int) {
 	}
 }
 
+// Follow keeps the view pinned to the end of the content as it grows
+func (v *Viewer) Follow() {
+	v.following = true
+	if f, ok := v.source.(reader.Follower); ok {
+		f.Follow(true)
+	}
+	v.GoToLine(v.source.LineCount() - 1)
+}
+
+// stopFollowing leaves follow mode, keeping the current position
+func (v *Viewer) stopFollowing() {
+	v.following = false
+	if f, ok := v.source.(reader.Follower); ok {
+		f.Follow(false)
+	}
+}
+
+// reload reads the input of the current source again, keeping the
+// position. Matches found in the previous input no longer apply.
+func (v *Viewer) reload() {
+	reloader, ok := v.source.(reader.Reloader)
+	if !ok {
+		v.message = "Reload is not available here"
+		return
+	}
+
+	if err := reloader.Reload(); err != nil {
+		v.message = fmt.Sprintf("Reload failed: %v", err)
+		return
+	}
+	v.clearSearch()
+}
+
+// togglePrefix shows or hides the metadata before each line
+func (v *Viewer) togglePrefix() {
+	toggler, ok := v.source.(reader.PrefixToggler)
+	if !ok || !toggler.TogglePrefix() {
+		v.message = "No line prefix to toggle here"
+	}
+}
+
+// showInfo shows the line count and, for files read from disk, how well
+// the line cache works
+func (v *Viewer) showInfo() {
+	v.message = fmt.Sprintf("%d lines", v.source.LineCount())
+
+	reporter, ok := v.source.(reader.CacheReporter)
+	if !ok {
+		return
+	}
+	stats := reporter.CacheStats()
+	v.message += fmt.Sprintf(", cache %s/%s, %d hits, %d misses",
+		formatSize(stats.Used), formatSize(stats.Budget), stats.Hits, stats.Misses)
+}
+
+// formatSize formats a byte count with a binary unit
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Tail shows the end of the content at once. Until the source is loaded,
+// the end is read backwards from EOF and line numbers are estimated.
+func (v *Viewer) Tail() {
+	v.tailing = true
+	v.tailOffset = 0
+}
+
+// tailLines returns the lines of the tail view, adjusting its offset if
+// the start of the content was reached
+func (v *Viewer) tailLines(n int) ([]string, error) {
+	var lines []string
+	if tailer, ok := v.source.(reader.Tailer); ok {
+		lines, _ = tailer.Tail(v.tailOffset + n)
+	}
+
+	// Streams only have an end once read, show the end of what arrived
+	if lines == nil {
+		total := v.source.LineCount()
+		var err error
+		lines, err = v.source.GetLines(max(total-v.tailOffset-n, 0), total)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v.tailOffset = min(v.tailOffset, max(len(lines)-n, 0))
+	return lines[max(len(lines)-v.tailOffset-n, 0) : len(lines)-v.tailOffset], nil
+}
+
+// endTail leaves the tail view for the same position with known line numbers
+func (v *Viewer) endTail() {
+	v.tailing = false
+	v.GoToLine(v.source.LineCount() - v.tailOffset - (v.height - 1))
+}
+
 // GoToLine moves to a specific line
 func (v *Viewer) GoToLine(line int) {
+	// The end is still shown by the tail view
+	if v.tailing {
+		if line >= v.source.LineCount()-1 {
+			v.tailOffset = 0
+			return
+		}
+		v.tailing = false
+	}
+
 	v.currentLine = line
+	v.rowOffset = 0
 	v.Scroll(0) // Normalize bounds
 }
 
@@ -285,11 +591,26 @@ func (v *Viewer) performSearch() {
 		return
 	}
 
+	if searcher, ok := v.source.(reader.Searcher); ok {
+		matches, err := searcher.Search(v.searchTerm)
+		if err != nil {
+			v.message = fmt.Sprintf("Search failed: %v", err)
+		}
+		for _, m := range matches {
+			v.searchResults = append(v.searchResults, SearchMatch{
+				Line:       m.Line,
+				MatchIndex: m.Index,
+				Length:     m.Length,
+			})
+		}
+		return
+	}
+
 	searchLower := strings.ToLower(v.searchTerm)
-	totalLines := v.fileReader.LineCount()
+	totalLines := v.source.LineCount()
 
 	for i := 0; i < totalLines; i++ {
-		line, err := v.fileReader.GetLine(i)
+		line, err := v.source.GetLine(i)
 		if err != nil {
 			continue
 		}
@@ -311,6 +632,7 @@ func (v *Viewer) performSearch() {
 			v.searchResults = append(v.searchResults, SearchMatch{
 				Line:       i,
 				MatchIndex: absIndex,
+				Length:     len(searchLower),
 			})
 
 			startIndex = absIndex + len(searchLower)
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/iqoologic/gless/internal/reader"
 	"github.com/iqoologic/gless/internal/viewer"
@@ -10,29 +16,250 @@ import (
 
 func main() {
 	// Parse command line arguments
-	args := os.Args[1:]
+	follow := flag.Bool("follow", false, "follow the file as it grows, like tail -f")
+	encoding := flag.String("encoding", "", "character encoding of the input: utf-8, utf-16le, utf-16be or latin-1 (default: detect)")
+	merge := flag.Bool("merge", false, "merge the files into one timeline ordered by line timestamps")
+	followName := flag.Bool("follow-name", false, "follow by name and reopen the file when it is rotated, like tail -F")
+	command := flag.String("cmd", "", "view the combined output of a shell command, R re-runs it")
+	listen := flag.String("listen", "", "show log messages received on udp://host:port, tcp://host:port or unix:///path")
+	maxMemory := flag.String("max-memory", "256M", "memory budget for cached lines of all files together and for piped input, e.g. 512M or 2G")
+	nulDelimited := flag.Bool("z", false, "lines are separated by NUL bytes, as from find -print0 or git log -z")
+	delimiter := flag.String("delimiter", "", "lines end in this byte sequence instead of a newline; \\t, \\0 and \\xHH escapes are understood")
+	recordLength := flag.Int("record-length", 0, "split the content into lines of this many bytes, for fixed-length records")
+	recordStart := flag.String("record-start", "", "group lines into records starting at lines matching this regular expression, e.g. '^\\d{4}-\\d\\d-\\d\\d'")
+	tee := flag.String("tee", "", "save stdin to this file as it is read")
+	tail := flag.Bool("tail", false, "open at the end of the file, like +G")
+	interval := flag.Duration("interval", 0, "re-run the --cmd command at this interval, e.g. 5s, marking changed lines")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gless [options] [+G] <filename>...")
+		fmt.Fprintln(os.Stderr, "       gless [options] -    (read from stdin)")
+		fmt.Fprintln(os.Stderr, "       gless [options] --cmd \"command\"")
+		fmt.Fprintln(os.Stderr, "       gless [options] --listen udp://127.0.0.1:5514")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(lessCommands(os.Args[1:], tail))
+	args := flag.Args()
 
-	var filename string
-	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "Usage: gless <filename>")
-		fmt.Fprintln(os.Stderr, "       gless -    (read from stdin)")
+	if len(args) == 0 && *command == "" && *listen == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *interval != 0 && *command == "" {
+		fmt.Fprintln(os.Stderr, "Error: --interval requires --cmd")
 		os.Exit(1)
 	}
 
-	filename = args[0]
+	memoryBudget, err := parseSize(*maxMemory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --max-memory: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Create file reader
-	fileReader, err := reader.NewFileReader(filename)
+	lineDelimiter, err := parseDelimiter(*delimiter)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: invalid --delimiter: %v\n", err)
+		os.Exit(1)
+	}
+	if *nulDelimited {
+		lineDelimiter = "\x00"
+	}
+	if *nulDelimited && *delimiter != "" || lineDelimiter != "" && *recordLength != 0 {
+		fmt.Fprintln(os.Stderr, "Error: -z, --delimiter and --record-length are exclusive")
+		os.Exit(1)
+	}
+	if *recordLength < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --record-length must be positive")
 		os.Exit(1)
 	}
-	defer fileReader.Close()
+
+	// Continuation lines, like stack traces, join the line they follow
+	var recordPattern *regexp.Regexp
+	if *recordStart != "" {
+		recordPattern, err = regexp.Compile(*recordStart)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --record-start: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	filenames := expandGlobs(args)
+
+	// Piped input is saved as it arrives, like tee
+	var teeFile *os.File
+	if *tee != "" {
+		if !slices.Contains(filenames, "-") {
+			fmt.Fprintln(os.Stderr, "Error: --tee requires reading stdin (-)")
+			os.Exit(1)
+		}
+		teeFile, err = os.Create(*tee)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating --tee file: %v\n", err)
+			os.Exit(1)
+		}
+		defer teeFile.Close()
+	}
+
+	// Create file readers
+	var fileReaders []reader.LineSource
+	if *command != "" {
+		commandReader, err := reader.NewCommandReader(*command, *interval, reader.Options{Encoding: *encoding})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running command: %v\n", err)
+			os.Exit(1)
+		}
+		defer commandReader.Close()
+		fileReaders = append(fileReaders, commandReader)
+	}
+	if *listen != "" {
+		listenReader, err := reader.NewListenReader(*listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listening: %v\n", err)
+			os.Exit(1)
+		}
+		defer listenReader.Close()
+		fileReaders = append(fileReaders, listenReader)
+	}
+	for _, filename := range filenames {
+		opts := reader.Options{
+			Encoding:     *encoding,
+			Delimiter:    lineDelimiter,
+			RecordLength: *recordLength,
+			MaxMemory:    memoryBudget,
+		}
+		if filename == "-" && teeFile != nil {
+			opts.Tee = teeFile
+		}
+
+		// Archives open as a list of their members
+		if reader.IsArchive(filename) {
+			archiveReader, err := reader.NewArchiveReader(filename, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening archive: %v\n", err)
+				os.Exit(1)
+			}
+			defer archiveReader.Close()
+			fileReaders = append(fileReaders, archiveReader)
+			continue
+		}
+
+		fileReader, err := reader.NewFileReader(filename, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+			os.Exit(1)
+		}
+		fileReader.SetFollowByName(*followName)
+
+		// Docker and Kubernetes container logs are decoded, other files
+		// are shown as they are
+		containerLogReader := reader.NewContainerLogReader(fileReader)
+		if recordPattern != nil {
+			recordReader := reader.NewRecordReader(containerLogReader, recordPattern)
+			defer recordReader.Close()
+			fileReaders = append(fileReaders, recordReader)
+			continue
+		}
+		defer containerLogReader.Close()
+		fileReaders = append(fileReaders, containerLogReader)
+	}
+
+	sources := fileReaders
+	if *merge {
+		mergeReader, err := reader.NewMergeReader(fileReaders)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging files: %v\n", err)
+			os.Exit(1)
+		}
+		defer mergeReader.Close()
+		sources = []reader.LineSource{mergeReader}
+	}
 
 	// Create and run viewer
-	v := viewer.NewViewer(fileReader)
+	v := viewer.NewViewer(sources...)
+	if *tail {
+		v.Tail()
+	}
+	if *follow || *followName || *listen != "" {
+		v.Follow()
+	}
 	if err := v.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error running viewer: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// lessCommands removes the less style +G option, which opens at the end,
+// from the arguments
+func lessCommands(args []string, tail *bool) []string {
+	var rest []string
+	for _, arg := range args {
+		if arg == "+G" {
+			*tail = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest
+}
+
+// parseDelimiter interprets the escape sequences of a --delimiter value,
+// which is hard to type otherwise. \0 alone is a NUL byte.
+func parseDelimiter(s string) (string, error) {
+	var b strings.Builder
+	for s != "" {
+		if strings.HasPrefix(s, `\0`) && (len(s) == 2 || s[2] < '0' || s[2] > '7') {
+			b.WriteByte(0)
+			s = s[2:]
+			continue
+		}
+
+		value, multibyte, rest, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return "", err
+		}
+		if value < 0x100 && !multibyte {
+			b.WriteByte(byte(value))
+		} else {
+			b.WriteRune(value)
+		}
+		s = rest
+	}
+	return b.String(), nil
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix
+func parseSize(s string) (int64, error) {
+	number := strings.TrimSuffix(strings.ToUpper(s), "B")
+	multiplier := int64(1)
+	if suffix := strings.IndexAny(number, "KMG"); suffix >= 0 && suffix == len(number)-1 {
+		multiplier = int64(1) << (10 * (strings.Index("KMG", number[suffix:]) + 1))
+		number = number[:suffix]
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a size like 512M", s)
+	}
+	return n * multiplier, nil
+}
+
+// expandGlobs expands wildcard patterns the shell did not expand, as on
+// Windows. Arguments that exist as files or match nothing are kept as is.
+func expandGlobs(args []string) []string {
+	var filenames []string
+	for _, arg := range args {
+		if _, err := os.Stat(arg); err == nil {
+			filenames = append(filenames, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil || len(matches) == 0 {
+			filenames = append(filenames, arg)
+			continue
+		}
+		filenames = append(filenames, matches...)
+	}
+	return filenames
+}